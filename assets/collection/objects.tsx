@@ -0,0 +1,14 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.2" tiledversion="1.2.4" name="objects" tilewidth="176" tileheight="144" tilecount="2" columns="0">
+ <tileoffset x="-8" y="4"/>
+ <grid orientation="orthogonal" width="1" height="1"/>
+ <tile id="0">
+  <image width="176" height="144" source="../embedded/overworld.png"/>
+ </tile>
+ <tile id="1" type="wall" probability="0.5">
+  <properties>
+   <property name="solid" type="bool" value="true"/>
+  </properties>
+  <image width="128" height="16" source="../external/track1_bg.png"/>
+ </tile>
+</tileset>
@@ -0,0 +1,4 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.2" tiledversion="1.2.4" name="track1_bg" tilewidth="8" tileheight="8" tilecount="32" columns="16">
+ <image source="../images/track1_bg.png" width="128" height="16"/>
+</tileset>
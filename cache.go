@@ -0,0 +1,45 @@
+package tmxmap
+
+import "sync"
+
+// TileSetCache holds external tilesets decoded once and shared between map loads, keyed by their source path
+// resolved against the file system. The zero value is an empty cache, safe for concurrent use.
+//
+// Maps loaded with a cache share the tiles and images of their external tilesets, which must not be modified.
+type TileSetCache struct {
+	mu      sync.Mutex
+	entries map[string]*tileSetCacheEntry
+}
+
+type tileSetCacheEntry struct {
+	once    sync.Once
+	tileSet TileSet
+	err     error
+}
+
+// load returns the cached tileset, decoding it with decode on first use. Failed decodes are not cached.
+func (c *TileSetCache) load(source string, decode func(ts *TileSet) error) (TileSet, error) {
+	c.mu.Lock()
+	if c.entries == nil {
+		c.entries = make(map[string]*tileSetCacheEntry)
+	}
+	entry, ok := c.entries[source]
+	if !ok {
+		entry = &tileSetCacheEntry{}
+		c.entries[source] = entry
+	}
+	c.mu.Unlock()
+
+	entry.once.Do(func() {
+		entry.err = decode(&entry.tileSet)
+	})
+	if entry.err != nil {
+		c.mu.Lock()
+		if c.entries[source] == entry {
+			delete(c.entries, source)
+		}
+		c.mu.Unlock()
+		return TileSet{}, entry.err
+	}
+	return entry.tileSet, nil
+}
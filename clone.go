@@ -0,0 +1,226 @@
+package tmxmap
+
+import "slices"
+
+// Clone returns a deep copy of the map. Tiles of the copy point to the tilesets of the copy. Decoded images,
+// Image.Image, are shared between the map and its copy as they are never modified by this package.
+func (m *Map) Clone() *Map {
+	c := cloner{tileSets: make(map[*TileSet]*TileSet, len(m.TileSets))}
+	clone := *m
+	clone.Properties = slices.Clone(m.Properties)
+	clone.EditorSettings = m.EditorSettings.clone()
+	if m.TileSets != nil {
+		clone.TileSets = make([]*TileSet, len(m.TileSets))
+	}
+	for i, ts := range m.TileSets {
+		tileSet := ts.clone()
+		clone.TileSets[i] = &tileSet
+		c.tileSets[ts] = &tileSet
+	}
+	clone.Layers = cloneEach(m.Layers, c.layer)
+	clone.ObjectGroups = cloneEach(m.ObjectGroups, c.objectGroup)
+	clone.ImageLayers = cloneEach(m.ImageLayers, (*ImageLayer).clone)
+	clone.Groups = cloneEach(m.Groups, c.group)
+	clone.Unknown = m.Unknown.clone()
+	clone.InvalidGIDs = make([]*InvalidGIDError, len(m.InvalidGIDs))
+	for i, invalid := range m.InvalidGIDs {
+		clone.InvalidGIDs[i] = clonePtr(invalid)
+	}
+	if m.InvalidGIDs == nil {
+		clone.InvalidGIDs = nil
+	}
+	clone.Warnings = slices.Clone(m.Warnings)
+	clone.tileSetOrder = slices.Clone(m.tileSetOrder)
+	return &clone
+}
+
+// cloner maps the tilesets of a map to the ones of its copy, so that tiles can be pointed to the copy.
+type cloner struct {
+	tileSets map[*TileSet]*TileSet
+}
+
+func (c cloner) tile(t *TileInfo) *TileInfo {
+	if t == nil || t == NilTile {
+		return t
+	}
+	clone := *t
+	if tileSet, ok := c.tileSets[t.TileSet]; ok {
+		clone.TileSet = tileSet
+	}
+	return &clone
+}
+
+func (c cloner) tiles(tiles []*TileInfo) []*TileInfo {
+	if tiles == nil {
+		return nil
+	}
+	clone := make([]*TileInfo, len(tiles))
+	for i, t := range tiles {
+		clone[i] = c.tile(t)
+	}
+	return clone
+}
+
+func (c cloner) layer(l *Layer) Layer {
+	clone := *l
+	clone.Properties = slices.Clone(l.Properties)
+	clone.Data = l.Data.clone()
+	for i := range clone.Data.Chunk {
+		clone.Data.Chunk[i].Tiles = c.tiles(l.Data.Chunk[i].Tiles)
+	}
+	clone.Tiles = c.tiles(l.Tiles)
+	clone.GIDs = slices.Clone(l.GIDs)
+	clone.Unknown = l.Unknown.clone()
+	return clone
+}
+
+func (c cloner) objectGroup(og *ObjectGroup) ObjectGroup {
+	clone := *og
+	clone.Properties = slices.Clone(og.Properties)
+	clone.Objects = cloneEach(og.Objects, c.object)
+	clone.Unknown = og.Unknown.clone()
+	return clone
+}
+
+func (c cloner) object(o *Object) Object {
+	clone := *o
+	clone.Properties = slices.Clone(o.Properties)
+	clone.Ellipse = clonePtr(o.Ellipse)
+	clone.Point = clonePtr(o.Point)
+	clone.Polygons = slices.Clone(o.Polygons)
+	clone.PolyLines = slices.Clone(o.PolyLines)
+	clone.Text = clonePtr(o.Text)
+	clone.Tile = c.tile(o.Tile)
+	clone.Unknown = o.Unknown.clone()
+	clone.attrs = slices.Clone(o.attrs)
+	if o.instance != nil {
+		instance := c.object(o.instance)
+		clone.instance = &instance
+	}
+	return clone
+}
+
+func (c cloner) group(g *Group) Group {
+	clone := *g
+	clone.Properties = slices.Clone(g.Properties)
+	clone.Layers = cloneEach(g.Layers, c.layer)
+	clone.ObjectGroups = cloneEach(g.ObjectGroups, c.objectGroup)
+	clone.ImageLayers = cloneEach(g.ImageLayers, (*ImageLayer).clone)
+	clone.Groups = cloneEach(g.Groups, c.group)
+	clone.Unknown = g.Unknown.clone()
+	return clone
+}
+
+func (ts *TileSet) clone() TileSet {
+	clone := *ts
+	clone.Properties = slices.Clone(ts.Properties)
+	clone.Image = ts.Image.clonePtr()
+	clone.Tiles = cloneEach(ts.Tiles, (*Tile).clone)
+	clone.Grid = clonePtr(ts.Grid)
+	clone.Transformations = clonePtr(ts.Transformations)
+	clone.WangSets = cloneEach(ts.WangSets, (*WangSet).clone)
+	clone.Unknown = ts.Unknown.clone()
+	return clone
+}
+
+func (t *Tile) clone() Tile {
+	clone := *t
+	clone.Properties = slices.Clone(t.Properties)
+	clone.Image = t.Image.clone()
+	clone.Animation = slices.Clone(t.Animation)
+	if t.ObjectGroup != nil {
+		objectGroup := cloner{}.objectGroup(t.ObjectGroup)
+		clone.ObjectGroup = &objectGroup
+	}
+	clone.Unknown = t.Unknown.clone()
+	return clone
+}
+
+func (ws *WangSet) clone() WangSet {
+	clone := *ws
+	clone.Properties = slices.Clone(ws.Properties)
+	clone.Colors = cloneEach(ws.Colors, func(wc *WangColor) WangColor {
+		color := *wc
+		color.Properties = slices.Clone(wc.Properties)
+		return color
+	})
+	clone.Tiles = slices.Clone(ws.Tiles)
+	return clone
+}
+
+func (il *ImageLayer) clone() ImageLayer {
+	clone := *il
+	clone.Properties = slices.Clone(il.Properties)
+	clone.Image = il.Image.clone()
+	clone.Unknown = il.Unknown.clone()
+	return clone
+}
+
+func (i *Image) clone() Image {
+	clone := *i
+	if i.Data != nil {
+		data := i.Data.clone()
+		clone.Data = &data
+	}
+	return clone
+}
+
+func (i *Image) clonePtr() *Image {
+	if i == nil {
+		return nil
+	}
+	clone := i.clone()
+	return &clone
+}
+
+// clone copies the data, chunk tiles included. Tiles still point to the tilesets of the original map.
+func (d *Data) clone() Data {
+	clone := *d
+	clone.RawData = slices.Clone(d.RawData)
+	clone.DataTiles = slices.Clone(d.DataTiles)
+	clone.Chunk = cloneEach(d.Chunk, func(chunk *Chunk) Chunk {
+		clone := *chunk
+		clone.RawData = slices.Clone(chunk.RawData)
+		clone.DataTiles = slices.Clone(chunk.DataTiles)
+		clone.GIDs = slices.Clone(chunk.GIDs)
+		clone.Tiles = slices.Clone(chunk.Tiles)
+		return clone
+	})
+	return clone
+}
+
+func (es *EditorSettings) clone() *EditorSettings {
+	if es == nil {
+		return nil
+	}
+	return &EditorSettings{ChunkSize: clonePtr(es.ChunkSize), Export: clonePtr(es.Export)}
+}
+
+func (u *Unknown) clone() Unknown {
+	return Unknown{
+		UnknownAttrs: slices.Clone(u.UnknownAttrs),
+		UnknownElements: cloneEach(u.UnknownElements, func(e *UnknownElement) UnknownElement {
+			return UnknownElement{XMLName: e.XMLName, Attrs: slices.Clone(e.Attrs), InnerXML: slices.Clone(e.InnerXML)}
+		}),
+	}
+}
+
+// cloneEach copies the items of a slice with the given function, keeping nil slices nil.
+func cloneEach[T any](items []T, clone func(*T) T) []T {
+	if items == nil {
+		return nil
+	}
+	clones := make([]T, len(items))
+	for i := range items {
+		clones[i] = clone(&items[i])
+	}
+	return clones
+}
+
+func clonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	clone := *p
+	return &clone
+}
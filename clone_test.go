@@ -0,0 +1,53 @@
+package tmxmap
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestClone(t *testing.T) {
+	for _, name := range []string{"assets/embedded/objects.tmx", "assets/embedded/group.tmx", "assets/infinite/infinite_csv.tmx"} {
+		tmx, err := Load(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		clone := tmx.Clone()
+		if !reflect.DeepEqual(clone, tmx) {
+			t.Errorf("%s: clone differs from the map", name)
+		}
+		if image := clone.TileSets[0].Image; image != nil && image.Image != tmx.TileSets[0].Image.Image {
+			t.Errorf("%s: decoded images should be shared", name)
+		}
+		clone.walkLayers(clone.Layers, clone.Groups, func(l *Layer) {
+			tiles := slices.Clone(l.Tiles)
+			for _, chunk := range l.Data.Chunk {
+				tiles = append(tiles, chunk.Tiles...)
+			}
+			for _, tile := range tiles {
+				if !tile.Nil && tile.TileSet != clone.TileSets[0] {
+					t.Errorf("%s: layer %q: tiles should point to the cloned tileset", name, l.Name)
+					return
+				}
+			}
+		})
+	}
+
+	tmx, err := Load("assets/embedded/objects.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone := tmx.Clone()
+	clone.TileSets[0].Name = "changed"
+	clone.ObjectGroups[0].Objects[0].Name = "changed"
+	clone.ObjectGroups[0].Objects[1].Polygons = append(clone.ObjectGroups[0].Objects[1].Polygons, Polygon{})
+	tree := &clone.ObjectGroups[0].Objects[5]
+	if tree.Tile.TileSet != clone.TileSets[0] {
+		t.Errorf("tile objects should point to the cloned tileset")
+	}
+	tree.Tile.ID = 42
+	if tmx.TileSets[0].Name == "changed" || tmx.ObjectGroups[0].Objects[0].Name == "changed" ||
+		len(tmx.ObjectGroups[0].Objects[1].Polygons) != 0 || tmx.ObjectGroups[0].Objects[5].Tile.ID == 42 {
+		t.Errorf("changing the clone should not change the map")
+	}
+}
@@ -0,0 +1,96 @@
+package tmxmap
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// ParseColor parses a Tiled color in the rrggbb or aarrggbb form, with or without a leading #.
+// An empty string yields the zero, fully transparent, color.
+func ParseColor(s string) (color.RGBA, error) {
+	if s == "" {
+		return color.RGBA{}, nil
+	}
+	hex := strings.TrimPrefix(s, "#")
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid color: %s", s)
+	}
+	switch len(hex) {
+	case 6:
+		return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+	case 8:
+		return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: uint8(v >> 24)}, nil
+	}
+	return color.RGBA{}, fmt.Errorf("invalid color: %s", s)
+}
+
+// BackgroundRGBA returns the background color of the map.
+func (m *Map) BackgroundRGBA() (color.RGBA, error) {
+	return ParseColor(m.BackgroundColor)
+}
+
+// ColorRGBA returns the color of the object group.
+func (og *ObjectGroup) ColorRGBA() (color.RGBA, error) {
+	return ParseColor(og.Color)
+}
+
+// ColorRGBA returns the color of the text, which defaults to opaque black.
+func (t *Text) ColorRGBA() (color.RGBA, error) {
+	if t.Color == "" {
+		return color.RGBA{A: 0xff}, nil
+	}
+	return ParseColor(t.Color)
+}
+
+// tint parses a tint color. Without tint color, the layer is tinted with opaque white, leaving it unchanged.
+func tint(s string) (color.RGBA, error) {
+	if s == "" {
+		return color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, nil
+	}
+	return ParseColor(s)
+}
+
+// TintRGBA returns the tint color of the layer, opaque white when not set.
+func (l *Layer) TintRGBA() (color.RGBA, error) {
+	return tint(l.TintColor)
+}
+
+// TintRGBA returns the tint color of the image layer, opaque white when not set.
+func (il *ImageLayer) TintRGBA() (color.RGBA, error) {
+	return tint(il.TintColor)
+}
+
+// TintRGBA returns the tint color of the object group, opaque white when not set.
+func (og *ObjectGroup) TintRGBA() (color.RGBA, error) {
+	return tint(og.TintColor)
+}
+
+// TintRGBA returns the tint color of the group, opaque white when not set.
+func (g *Group) TintRGBA() (color.RGBA, error) {
+	return tint(g.TintColor)
+}
+
+// applyTransparency returns a copy of the image where the pixels of the transparent color are fully transparent.
+func applyTransparency(img image.Image, trans string) (*image.NRGBA, error) {
+	key, err := ParseColor(trans)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	nrgba := image.NewNRGBA(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.R == key.R && c.G == key.G && c.B == key.B {
+				c = color.NRGBA{}
+			}
+			nrgba.SetNRGBA(x, y, c)
+		}
+	}
+	return nrgba, nil
+}
@@ -0,0 +1,75 @@
+package tmxmap
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected color.RGBA
+	}{
+		{"", color.RGBA{}},
+		{"#ff8000", color.RGBA{R: 0xff, G: 0x80, A: 0xff}},
+		{"ff8000", color.RGBA{R: 0xff, G: 0x80, A: 0xff}},
+		{"#40ff8000", color.RGBA{R: 0xff, G: 0x80, A: 0x40}},
+		{"40ff8000", color.RGBA{R: 0xff, G: 0x80, A: 0x40}},
+	}
+	for _, test := range tests {
+		c, err := ParseColor(test.in)
+		if err != nil {
+			t.Errorf("%q: %v", test.in, err)
+		}
+		if c != test.expected {
+			t.Errorf("%q: expected %v, got %v", test.in, test.expected, c)
+		}
+	}
+	for _, invalid := range []string{"#fff", "#gg0000", "#ff00000"} {
+		if _, err := ParseColor(invalid); err == nil {
+			t.Errorf("%q: expected an error", invalid)
+		}
+	}
+}
+
+func TestTintRGBA(t *testing.T) {
+	if c, err := (&Layer{}).TintRGBA(); err != nil || c != (color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}) {
+		t.Errorf("expected opaque white, got %v (%v)", c, err)
+	}
+	if c, err := (&ImageLayer{TintColor: "#804020"}).TintRGBA(); err != nil || c != (color.RGBA{R: 0x80, G: 0x40, B: 0x20, A: 0xff}) {
+		t.Errorf("unexpected tint: %v (%v)", c, err)
+	}
+}
+
+func TestApplyTransparency(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	src.Set(0, 0, color.RGBA{R: 0xff, B: 0xff, A: 0xff})
+	src.Set(1, 0, color.RGBA{R: 0x10, G: 0x20, B: 0x30, A: 0xff})
+	tileMap := `<map width="1" height="1"><tileset firstgid="1" name="tiles"><image source="tiles.png" trans="ff00ff"/></tileset></map>`
+
+	for _, apply := range []bool{false, true} {
+		options := LoadOptions{
+			ImageLoader:       func(string) (image.Image, error) { return src, nil },
+			ApplyTransparency: apply,
+		}
+		tmx, err := options.Decode(strings.NewReader(tileMap))
+		if err != nil {
+			t.Fatal(err)
+		}
+		img := tmx.TileSets[0].Image.Image
+		if !apply {
+			if img != src {
+				t.Errorf("image should be left as is without the option")
+			}
+			continue
+		}
+		if _, _, _, a := img.At(0, 0).RGBA(); a != 0 {
+			t.Errorf("transparent color should be fully transparent, got alpha %d", a)
+		}
+		if c := color.NRGBAModel.Convert(img.At(1, 0)); c != (color.NRGBA{R: 0x10, G: 0x20, B: 0x30, A: 0xff}) {
+			t.Errorf("other colors should be kept, got %v", c)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package tmxmap
+
+import "fmt"
+
+// AddLayer appends a tile layer to the map, assigning it the next layer ID. The returned pointer is invalidated by
+// the next change to m.Layers.
+func (m *Map) AddLayer(layer Layer) *Layer {
+	layer.ID = m.nextLayerID()
+	m.Layers = append(m.Layers, layer)
+	return &m.Layers[len(m.Layers)-1]
+}
+
+// AddObject appends an object to an object group of the map, assigning it the next object ID. Object IDs are
+// unique across the map, which is why the map is the one handing them out. The returned pointer is invalidated by
+// the next change to og.Objects.
+func (m *Map) AddObject(og *ObjectGroup, object Object) *Object {
+	object.ID = m.nextObjectID()
+	og.Objects = append(og.Objects, object)
+	return &og.Objects[len(og.Objects)-1]
+}
+
+// AddTileSet appends a tileset to the map, assigning it the first GID following the GID ranges of the tilesets of
+// the map. It fails when the range of a tileset is unknown, as for external tilesets left unresolved, since the GIDs
+// it covers could then overlap the ones of the new tileset.
+func (m *Map) AddTileSet(ts TileSet) (*TileSet, error) {
+	if ts.tileRange() == 0 {
+		return nil, fmt.Errorf("tileset %q: unknown tile count", ts.Name)
+	}
+	ts.FirstGID = 1
+	for _, tileSet := range m.TileSets {
+		n := tileSet.tileRange()
+		if n == 0 {
+			return nil, fmt.Errorf("tileset %q: unknown tile count", tileSet.Name)
+		}
+		ts.FirstGID = max(ts.FirstGID, tileSet.FirstGID+GID(n))
+	}
+	m.TileSets = append(m.TileSets, &ts)
+	m.indexTileSets()
+	return &ts, nil
+}
+
+// nextLayerID returns the next layer ID and increments the counter. Maps saved without the counter start after
+// the highest layer ID in use.
+func (m *Map) nextLayerID() int {
+	if m.NextLayerID == 0 {
+		m.NextLayerID = maxLayerID(m.Layers, m.ObjectGroups, m.ImageLayers, m.Groups) + 1
+	}
+	id := m.NextLayerID
+	m.NextLayerID++
+	return id
+}
+
+// nextObjectID returns the next object ID and increments the counter. Maps saved without the counter start after
+// the highest object ID in use.
+func (m *Map) nextObjectID() int {
+	if m.NextObjectID == 0 {
+		maxID := 0
+		m.walkObjectGroups(m.ObjectGroups, m.Groups, func(og *ObjectGroup) error {
+			for _, object := range og.Objects {
+				maxID = max(maxID, object.ID)
+			}
+			return nil
+		})
+		m.NextObjectID = maxID + 1
+	}
+	id := m.NextObjectID
+	m.NextObjectID++
+	return id
+}
+
+func maxLayerID(layers []Layer, objectGroups []ObjectGroup, imageLayers []ImageLayer, groups []Group) int {
+	maxID := 0
+	for _, l := range layers {
+		maxID = max(maxID, l.ID)
+	}
+	for _, og := range objectGroups {
+		maxID = max(maxID, og.ID)
+	}
+	for _, il := range imageLayers {
+		maxID = max(maxID, il.ID)
+	}
+	for _, g := range groups {
+		maxID = max(maxID, g.ID, maxLayerID(g.Layers, g.ObjectGroups, g.ImageLayers, g.Groups))
+	}
+	return maxID
+}
@@ -0,0 +1,117 @@
+package tmxmap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAddLayer(t *testing.T) {
+	tmx, err := Load("assets/external/track1_bg.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	layer := tmx.AddLayer(Layer{Name: "Layer 2", Width: 32, Height: 6})
+	if layer.ID != 8 || tmx.NextLayerID != 9 {
+		t.Errorf("expected layer ID 8 and next ID 9, got %d and %d", layer.ID, tmx.NextLayerID)
+	}
+	if tmx.Layers[1].Name != "Layer 2" {
+		t.Errorf("layer should be appended to the map")
+	}
+}
+
+func TestAddObject(t *testing.T) {
+	tmx, err := Load("assets/embedded/objects.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	object := tmx.AddObject(&tmx.ObjectGroups[0], Object{Name: "chest"})
+	if object.ID != 8 || tmx.NextObjectID != 9 {
+		t.Errorf("expected object ID 8 and next ID 9, got %d and %d", object.ID, tmx.NextObjectID)
+	}
+	if len(tmx.ObjectGroups[0].Objects) != 8 {
+		t.Errorf("object should be appended to the group")
+	}
+}
+
+func TestAddWithoutCounters(t *testing.T) {
+	tmx, err := Decode(strings.NewReader(`<map width="1" height="1">
+ <layer id="3" name="Ground" width="1" height="1"><data encoding="csv">0</data></layer>
+ <group id="4" name="Group">
+  <objectgroup id="5" name="Objects"><object id="6"/></objectgroup>
+ </group>
+</map>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if layer := tmx.AddLayer(Layer{}); layer.ID != 6 {
+		t.Errorf("expected layer ID 6, got %d", layer.ID)
+	}
+	if object := tmx.AddObject(&tmx.Groups[0].ObjectGroups[0], Object{}); object.ID != 7 {
+		t.Errorf("expected object ID 7, got %d", object.ID)
+	}
+}
+
+func TestAddTileSet(t *testing.T) {
+	tmx, err := Load("assets/embedded/objects.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := &tmx.ObjectGroups[0].Objects[5]
+	tmx.TileSets = tmx.TileSets[:1:1]
+
+	tileSet, err := tmx.AddTileSet(TileSet{Name: "items", Tilecount: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tileSet.FirstGID != 100 || tmx.TileSets[1].Name != "items" {
+		t.Errorf("unexpected tileset: %+v", tileSet)
+	}
+	if tree.Tile.TileSet != tmx.TileSets[0] {
+		t.Errorf("object tile should still point to its tileset")
+	}
+	if tile, err := tmx.TileByGID(101); err != nil || tile.TileSet != tileSet || tile.ID != 1 {
+		t.Errorf("GIDs of the new tileset should resolve, got %+v, %v", tile, err)
+	}
+
+	second, err := tmx.AddTileSet(TileSet{Name: "more", Tiles: []Tile{{ID: 0}, {ID: 5}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.FirstGID != 116 {
+		t.Errorf("unexpected first GID: %d", second.FirstGID)
+	}
+	if third, err := tmx.AddTileSet(TileSet{Name: "last", Tilecount: 1}); err != nil || third.FirstGID != 122 {
+		t.Errorf("the first GID should follow the highest tile ID, got %+v, %v", third, err)
+	}
+	if _, err := tmx.AddTileSet(TileSet{Name: "empty"}); err == nil {
+		t.Errorf("expected an error for a tileset without tile count")
+	}
+
+	unresolved, err := Decode(strings.NewReader(`<map width="1" height="1"><tileset firstgid="1" source="missing.tsx"/></map>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := unresolved.AddTileSet(TileSet{Name: "items", Tilecount: 16}); err == nil {
+		t.Errorf("expected an error after an unresolved external tileset")
+	}
+
+	track, err := Load("assets/external/track1_bg.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tileSet = track.TileSets[0]
+	track.TileSets = track.TileSets[:1:1]
+	track.TileSets = append(track.TileSets, &TileSet{FirstGID: 1000, Name: "items"})
+	for i, tile := range track.Layers[0].Tiles {
+		if !tile.Nil && (tile.TileSet != track.TileSets[0] || tile.TileSet.Name != tileSet.Name) {
+			t.Fatalf("tile %d should still point to the tileset of the map", i)
+		}
+	}
+	track.TileSets[0].Name = "renamed"
+	if tile := track.Layers[0].Tiles[17]; tile.Nil || tile.TileSet.Name != "renamed" {
+		t.Errorf("tiles should see changes made to the tileset after appending, got %+v", tile)
+	}
+}
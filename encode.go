@@ -0,0 +1,875 @@
+package tmxmap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// EncodeOptions configures how maps are encoded. The zero value keeps the data encoding of each layer.
+type EncodeOptions struct {
+	// Encoding of the layer data: csv or base64. Empty keeps the encoding of each layer.
+	Encoding string
+
+	// Compression of base64 encoded layer data: gzip, zlib, zstd, or empty for none.
+	Compression string
+}
+
+// Encode writes the map as TMX. Layer data is re-encoded from the layer tiles.
+func (m *Map) Encode(w io.Writer) error {
+	return EncodeOptions{}.Encode(w, m)
+}
+
+// Save writes the map as TMX to the named file.
+func (m *Map) Save(name string) error {
+	return EncodeOptions{}.Save(name, m)
+}
+
+// Save writes the map as TMX to the named file using the options.
+func (o EncodeOptions) Save(name string, m *Map) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := o.Encode(file, m); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Encode writes the map as TMX using the options, preceded by the XML declaration Tiled writes. Layer data is
+// re-encoded from the layer tiles and compressed with the compression level of the map, -1 selecting the default
+// level. Maps built in code, rather than decoded, select the default level with a zero CompressionLevel as well.
+// Tiles replaced with NilTile by LoadOptions.IgnoreInvalidGID are encoded with their original GID.
+func (o EncodeOptions) Encode(w io.Writer, m *Map) error {
+	encoded := *m
+	if !m.unmarshalled && m.CompressionLevel == 0 {
+		encoded.CompressionLevel = -1
+	}
+	var err error
+	if encoded.Layers, err = o.encodeLayers(m.Layers, encoded.CompressionLevel); err != nil {
+		return err
+	}
+	if encoded.Groups, err = o.encodeGroups(m.Groups, encoded.CompressionLevel); err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", " ")
+	if err := encoder.Encode(&encoded); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// encodeLayers returns a copy of the layers with their data re-encoded, compressed with the given level.
+func (o EncodeOptions) encodeLayers(layers []Layer, level int) ([]Layer, error) {
+	encoded := make([]Layer, len(layers))
+	for i := range layers {
+		encoded[i] = layers[i]
+		encoding, compression := layers[i].Data.Encoding, layers[i].Data.Compression
+		if o.Encoding != "" {
+			encoding, compression = o.Encoding, o.Compression
+		}
+		data, err := layers[i].encodeData(encoding, compression, level)
+		if err != nil {
+			return nil, err
+		}
+		encoded[i].Data = data
+	}
+	return encoded, nil
+}
+
+// encodeGroups returns a copy of the groups with the data of their layers re-encoded.
+func (o EncodeOptions) encodeGroups(groups []Group, level int) ([]Group, error) {
+	encoded := make([]Group, len(groups))
+	for i := range groups {
+		encoded[i] = groups[i]
+		var err error
+		if encoded[i].Layers, err = o.encodeLayers(groups[i].Layers, level); err != nil {
+			return nil, err
+		}
+		if encoded[i].Groups, err = o.encodeGroups(groups[i].Groups, level); err != nil {
+			return nil, err
+		}
+	}
+	return encoded, nil
+}
+
+// gid returns the global tile ID of the tile, including its flip flags.
+func (t *TileInfo) gid() GID {
+	if t == nil || t.Nil || t.TileSet == nil {
+		return 0
+	}
+	gid := t.TileSet.FirstGID + t.ID
+	if t.HorizontalFlip {
+		gid |= horizontalFlip
+	}
+	if t.VerticalFlip {
+		gid |= verticalFlip
+	}
+	if t.DiagonalFlip {
+		gid |= diagonalFlip
+	}
+	return gid
+}
+
+func tileGIDs(tiles []*TileInfo) []GID {
+	gids := make([]GID, len(tiles))
+	for i, tile := range tiles {
+		gids[i] = tile.gid()
+	}
+	return gids
+}
+
+// encodedGIDs returns the GIDs to encode for the tiles. NilTile entries keep their decoded GID, so that GIDs ignored
+// with LoadOptions.IgnoreInvalidGID are written back unchanged.
+func encodedGIDs(tiles []*TileInfo, decoded []GID) []GID {
+	gids := tileGIDs(tiles)
+	for i, tile := range tiles {
+		if tile == NilTile && i < len(decoded) {
+			gids[i] = decoded[i]
+		}
+	}
+	return gids
+}
+
+// EncodeData encodes the layer tiles, flip flags included, with the given encoding (csv, base64, or empty for XML
+// tile elements) and compression (gzip, zlib, zstd, or empty for none). It is the inverse of the layer decoding.
+// A tile set to NilTile keeps its GID from GIDs, which preserves the GIDs ignored with LoadOptions.IgnoreInvalidGID;
+// clear both to empty a tile.
+// Layers whose tiles are not decoded are encoded from their current data. Data is compressed with the default
+// level, Map.Encode uses the compression level of the map.
+func (l *Layer) EncodeData(encoding, compression string) (Data, error) {
+	return l.encodeData(encoding, compression, -1)
+}
+
+func (l *Layer) encodeData(encoding, compression string, level int) (Data, error) {
+	data := Data{Encoding: encoding, Compression: compression}
+	if len(l.Data.Chunk) > 0 {
+		data.Chunk = make([]Chunk, len(l.Data.Chunk))
+		for i := range l.Data.Chunk {
+			chunk := &l.Data.Chunk[i]
+			var err error
+			gids := encodedGIDs(chunk.Tiles, chunk.GIDs)
+			if chunk.Tiles == nil {
+				if gids, err = chunk.decode(&l.Data); err != nil {
+					return Data{}, err
+				}
+			}
+			encoded := Chunk{X: chunk.X, Y: chunk.Y, Width: chunk.Width, Height: chunk.Height, Tiles: chunk.Tiles}
+			if encoded.RawData, encoded.DataTiles, err = data.encode(gids, chunk.Width, level); err != nil {
+				return Data{}, err
+			}
+			data.Chunk[i] = encoded
+		}
+		return data, nil
+	}
+
+	var err error
+	gids := encodedGIDs(l.Tiles, l.GIDs)
+	if l.Tiles == nil {
+		if gids, err = l.decode(); err != nil {
+			return Data{}, err
+		}
+	}
+	if data.RawData, data.DataTiles, err = data.encode(gids, l.Width, level); err != nil {
+		return Data{}, err
+	}
+	return data, nil
+}
+
+// encode encodes the GIDs according to the data encoding and compression. CSV rows are width tiles long.
+func (d *Data) encode(gids []GID, width, level int) ([]byte, []DataTile, error) {
+	switch d.Encoding {
+	case "":
+		dataTiles := make([]DataTile, len(gids))
+		for i, gid := range gids {
+			dataTiles[i].GID = gid
+		}
+		return nil, dataTiles, nil
+	case "csv":
+		var buf bytes.Buffer
+		buf.WriteByte('\n')
+		for i, gid := range gids {
+			buf.WriteString(strconv.FormatUint(uint64(gid), 10))
+			if i < len(gids)-1 {
+				buf.WriteByte(',')
+				if width > 0 && (i+1)%width == 0 {
+					buf.WriteByte('\n')
+				}
+			}
+		}
+		buf.WriteByte('\n')
+		return buf.Bytes(), nil, nil
+	case "base64":
+		raw := make([]byte, len(gids)*4)
+		for i, gid := range gids {
+			raw[i*4] = byte(gid)
+			raw[i*4+1] = byte(gid >> 8)
+			raw[i*4+2] = byte(gid >> 16)
+			raw[i*4+3] = byte(gid >> 24)
+		}
+		compressed, err := d.compress(raw, level)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []byte("\n" + base64.StdEncoding.EncodeToString(compressed) + "\n"), nil, nil
+	}
+	return nil, nil, fmt.Errorf("unsupported encoding: %s", d.Encoding)
+}
+
+// compress compresses data according to the data compression. A level of -1 selects the default compression level.
+func (d *Data) compress(data []byte, level int) ([]byte, error) {
+	var buf bytes.Buffer
+	var writer io.WriteCloser
+	var err error
+	switch d.Compression {
+	case "":
+		return data, nil
+	case "gzip":
+		writer, err = gzip.NewWriterLevel(&buf, level)
+	case "zlib":
+		writer, err = zlib.NewWriterLevel(&buf, level)
+	case "zstd":
+		var options []zstd.EOption
+		if level != -1 {
+			options = append(options, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		var zw *zstd.Encoder
+		if zw, err = zstd.NewWriter(&buf, options...); err != nil {
+			return nil, fmt.Errorf("zstd: %w", err)
+		}
+		writer = zw
+	default:
+		return nil, fmt.Errorf("unsupported compression: %s", d.Compression)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// attrs builds the attributes of an element, omitting the ones matching Tiled's defaults.
+type attrs []xml.Attr
+
+func (a *attrs) add(name, value string) {
+	*a = append(*a, xml.Attr{Name: xml.Name{Local: name}, Value: value})
+}
+
+func (a *attrs) string(name, value string) {
+	if value != "" {
+		a.add(name, value)
+	}
+}
+
+func (a *attrs) int(name string, value int) {
+	if value != 0 {
+		a.add(name, strconv.Itoa(value))
+	}
+}
+
+func (a *attrs) float(name string, value, def float64) {
+	if value != def {
+		a.add(name, strconv.FormatFloat(value, 'f', -1, 64))
+	}
+}
+
+func (a *attrs) float32(name string, value, def float32) {
+	if value != def {
+		a.add(name, strconv.FormatFloat(float64(value), 'f', -1, 32))
+	}
+}
+
+func (a *attrs) bool(name string, value, def bool) {
+	if value == def {
+		return
+	}
+	if value {
+		a.add(name, "1")
+	} else {
+		a.add(name, "0")
+	}
+}
+
+func element(name string, a attrs) xml.StartElement {
+	return xml.StartElement{Name: xml.Name{Local: name}, Attr: a}
+}
+
+// encodeElements encodes each item as an element with the given name.
+func encodeElements[T any](e *xml.Encoder, name string, items []T) error {
+	for i := range items {
+		if err := e.EncodeElement(&items[i], element(name, nil)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encodeProperties(e *xml.Encoder, properties []Property) error {
+	if len(properties) == 0 {
+		return nil
+	}
+	start := element("properties", nil)
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := encodeElements(e, "property", properties); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// MarshalXML encodes the map as a TMX map element.
+func (m *Map) MarshalXML(e *xml.Encoder, _ xml.StartElement) error {
+	var a attrs
+	a.string("version", m.Version)
+	a.string("tiledversion", m.TiledVersion)
+	a.string("class", m.Class)
+	a.string("orientation", m.Orientation)
+	a.string("renderorder", m.RenderOrder)
+	a.add("width", strconv.Itoa(m.Width))
+	a.add("height", strconv.Itoa(m.Height))
+	a.add("tilewidth", strconv.Itoa(m.TileWidth))
+	a.add("tileheight", strconv.Itoa(m.TileHeight))
+	a.bool("infinite", m.Infinite, false)
+	a.int("hexsidelength", m.HexSideLength)
+	a.string("staggeraxis", string(m.StaggerAxis))
+	a.string("staggerindex", string(m.StaggerIndex))
+	a.float("parallaxoriginx", m.ParallaxOriginX, 0)
+	a.float("parallaxoriginy", m.ParallaxOriginY, 0)
+	a.string("backgroundcolor", m.BackgroundColor)
+	a.int("nextlayerid", m.NextLayerID)
+	a.int("nextobjectid", m.NextObjectID)
+	if m.CompressionLevel != -1 {
+		a.add("compressionlevel", strconv.Itoa(m.CompressionLevel))
+	}
+	a = append(a, m.UnknownAttrs...)
+	start := element("map", a)
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if m.EditorSettings != nil {
+		if err := e.EncodeElement(m.EditorSettings, element("editorsettings", nil)); err != nil {
+			return err
+		}
+	}
+	if err := encodeProperties(e, m.Properties); err != nil {
+		return err
+	}
+	if err := encodeElements(e, "tileset", m.TileSets); err != nil {
+		return err
+	}
+	if err := encodeLayers(e, m.AllLayers()); err != nil {
+		return err
+	}
+	if err := m.encodeUnknown(e); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// encodeUnknown encodes the elements this package does not support as they were decoded.
+func (u *Unknown) encodeUnknown(e *xml.Encoder) error {
+	for i := range u.UnknownElements {
+		if err := e.Encode(&u.UnknownElements[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeLayers encodes the layers of a map or group in document order.
+func encodeLayers(e *xml.Encoder, layers []LayerLike) error {
+	for _, layer := range layers {
+		var name string
+		switch layer.(type) {
+		case *Layer:
+			name = "layer"
+		case *ObjectGroup:
+			name = "objectgroup"
+		case *ImageLayer:
+			name = "imagelayer"
+		case *Group:
+			name = "group"
+		}
+		if err := e.EncodeElement(layer, element(name, nil)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalXML encodes the transformations with Tiled's 0 and 1 booleans.
+func (t *Transformations) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	var a attrs
+	a.bool("hflip", t.HFlip, false)
+	a.bool("vflip", t.VFlip, false)
+	a.bool("rotate", t.Rotate, false)
+	a.bool("preferuntransformed", t.PreferUntransformed, false)
+	return e.EncodeElement(struct{}{}, element(start.Name.Local, a))
+}
+
+// MarshalXML encodes the tileset. External tilesets only encode their first GID and source.
+func (ts *TileSet) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	var a attrs
+	a.add("firstgid", strconv.FormatUint(uint64(ts.FirstGID), 10))
+	if ts.Source != "" {
+		a.add("source", ts.Source)
+		return e.EncodeElement(struct{}{}, element(start.Name.Local, a))
+	}
+	a.string("name", ts.Name)
+	a.string("class", ts.Class)
+	a.int("tilewidth", ts.TileWidth)
+	a.int("tileheight", ts.TileHeight)
+	a.int("spacing", ts.Spacing)
+	a.int("margin", ts.Margin)
+	a.int("tilecount", ts.Tilecount)
+	a.add("columns", strconv.Itoa(ts.Columns))
+	if ts.ObjectAlignment != "unspecified" {
+		a.string("objectalignment", ts.ObjectAlignment)
+	}
+	if ts.FillMode != "stretch" {
+		a.string("fillmode", ts.FillMode)
+	}
+	a = append(a, ts.UnknownAttrs...)
+	start = element(start.Name.Local, a)
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if ts.TileOffset != (TileOffset{}) {
+		if err := e.EncodeElement(ts.TileOffset, element("tileoffset", nil)); err != nil {
+			return err
+		}
+	}
+	if ts.Grid != nil {
+		if err := e.EncodeElement(ts.Grid, element("grid", nil)); err != nil {
+			return err
+		}
+	}
+	if ts.Transformations != nil {
+		if err := e.EncodeElement(ts.Transformations, element("transformations", nil)); err != nil {
+			return err
+		}
+	}
+	if err := encodeProperties(e, ts.Properties); err != nil {
+		return err
+	}
+	if ts.Image != nil {
+		if err := e.EncodeElement(ts.Image, element("image", nil)); err != nil {
+			return err
+		}
+	}
+	if err := encodeElements(e, "tile", ts.Tiles); err != nil {
+		return err
+	}
+	if len(ts.WangSets) > 0 {
+		wangSets := element("wangsets", nil)
+		if err := e.EncodeToken(wangSets); err != nil {
+			return err
+		}
+		if err := encodeElements(e, "wangset", ts.WangSets); err != nil {
+			return err
+		}
+		if err := e.EncodeToken(wangSets.End()); err != nil {
+			return err
+		}
+	}
+	if err := ts.encodeUnknown(e); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// MarshalXML encodes the tile and its image, collision shapes and animation.
+func (t *Tile) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	var a attrs
+	a.add("id", strconv.FormatUint(uint64(t.ID), 10))
+	a.string("type", t.Type)
+	a.string("class", t.Class)
+	a.float("probability", t.Probability, 1)
+	a = append(a, t.UnknownAttrs...)
+	start = element(start.Name.Local, a)
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := encodeProperties(e, t.Properties); err != nil {
+		return err
+	}
+	if t.Image.Source != "" || t.Image.Data != nil {
+		if err := e.EncodeElement(&t.Image, element("image", nil)); err != nil {
+			return err
+		}
+	}
+	if t.ObjectGroup != nil {
+		if err := e.EncodeElement(t.ObjectGroup, element("objectgroup", nil)); err != nil {
+			return err
+		}
+	}
+	if len(t.Animation) > 0 {
+		animation := element("animation", nil)
+		if err := e.EncodeToken(animation); err != nil {
+			return err
+		}
+		if err := encodeElements(e, "frame", t.Animation); err != nil {
+			return err
+		}
+		if err := e.EncodeToken(animation.End()); err != nil {
+			return err
+		}
+	}
+	if err := t.encodeUnknown(e); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// MarshalXML encodes the image reference or embedded image data.
+func (i *Image) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	var a attrs
+	a.string("format", i.Format)
+	a.string("source", i.Source)
+	a.string("trans", i.Trans)
+	a.int("width", i.Width)
+	a.int("height", i.Height)
+	start = element(start.Name.Local, a)
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if i.Data != nil {
+		if err := e.EncodeElement(i.Data, element("data", nil)); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// MarshalXML encodes the layer and its data.
+func (l *Layer) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	var a attrs
+	a.int("id", l.ID)
+	a.string("name", l.Name)
+	a.string("class", l.Class)
+	a.int("x", l.X)
+	a.int("y", l.Y)
+	a.add("width", strconv.Itoa(l.Width))
+	a.add("height", strconv.Itoa(l.Height))
+	a.float32("opacity", l.Opacity, 1)
+	a.bool("visible", l.Visible, true)
+	a.bool("locked", l.Locked, false)
+	a.string("tintcolor", l.TintColor)
+	a.int("offsetx", l.OffsetX)
+	a.int("offsety", l.OffsetY)
+	a.float("parallaxx", l.ParallaxX, 1)
+	a.float("parallaxy", l.ParallaxY, 1)
+	a = append(a, l.UnknownAttrs...)
+	start = element(start.Name.Local, a)
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := encodeProperties(e, l.Properties); err != nil {
+		return err
+	}
+	if err := e.EncodeElement(&l.Data, element("data", nil)); err != nil {
+		return err
+	}
+	if err := l.encodeUnknown(e); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// MarshalXML encodes the data, either as chunks, tile elements, or encoded text.
+func (d *Data) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	var a attrs
+	a.string("encoding", d.Encoding)
+	a.string("compression", d.Compression)
+	start = element(start.Name.Local, a)
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if len(d.Chunk) > 0 {
+		for i := range d.Chunk {
+			chunk := &d.Chunk[i]
+			var a attrs
+			a.add("x", strconv.Itoa(chunk.X))
+			a.add("y", strconv.Itoa(chunk.Y))
+			a.add("width", strconv.Itoa(chunk.Width))
+			a.add("height", strconv.Itoa(chunk.Height))
+			if err := d.encodeContent(e, element("chunk", a), chunk.RawData, chunk.DataTiles); err != nil {
+				return err
+			}
+		}
+		return e.EncodeToken(start.End())
+	}
+	if err := d.encodeContent(e, xml.StartElement{}, d.RawData, d.DataTiles); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// encodeContent encodes tile elements or encoded text, wrapped in the start element when it has a name.
+func (d *Data) encodeContent(e *xml.Encoder, start xml.StartElement, rawData []byte, dataTiles []DataTile) error {
+	if start.Name.Local != "" {
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+	}
+	if d.Encoding == "" {
+		for _, dataTile := range dataTiles {
+			var a attrs
+			if dataTile.GID != 0 {
+				a.add("gid", strconv.FormatUint(uint64(dataTile.GID), 10))
+			}
+			if err := e.EncodeElement(struct{}{}, element("tile", a)); err != nil {
+				return err
+			}
+		}
+	} else if err := e.EncodeToken(xml.CharData(rawData)); err != nil {
+		return err
+	}
+	if start.Name.Local != "" {
+		return e.EncodeToken(start.End())
+	}
+	return nil
+}
+
+// MarshalXML encodes the object group and its objects.
+func (og *ObjectGroup) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	var a attrs
+	a.int("id", og.ID)
+	a.string("name", og.Name)
+	a.string("class", og.Class)
+	a.string("color", og.Color)
+	a.float32("opacity", og.Opacity, 1)
+	a.bool("visible", og.Visible, true)
+	a.bool("locked", og.Locked, false)
+	a.string("tintcolor", og.TintColor)
+	a.int("offsetx", og.OffsetX)
+	a.int("offsety", og.OffsetY)
+	a.float("parallaxx", og.ParallaxX, 1)
+	a.float("parallaxy", og.ParallaxY, 1)
+	if og.DrawOrder != "topdown" {
+		a.string("draworder", og.DrawOrder)
+	}
+	a = append(a, og.UnknownAttrs...)
+	start = element(start.Name.Local, a)
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := encodeProperties(e, og.Properties); err != nil {
+		return err
+	}
+	if err := encodeElements(e, "object", og.Objects); err != nil {
+		return err
+	}
+	if err := og.encodeUnknown(e); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// MarshalXML encodes the object and its shape. A template instance only encodes the attributes, properties and
+// shape it declares, so that the template keeps providing the others.
+func (o *Object) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	own := o
+	var a attrs
+	if o.instance != nil {
+		own = o.instance
+		a = o.instanceAttrs()
+	} else {
+		a.int("id", o.ID)
+		a.string("template", o.Template)
+		a.string("name", o.Name)
+		if o.Type != o.Class {
+			a.string("type", o.Type)
+		}
+		a.string("class", o.Class)
+		a.int("gid", o.GID)
+		a.add("x", strconv.FormatFloat(o.X, 'f', -1, 64))
+		a.add("y", strconv.FormatFloat(o.Y, 'f', -1, 64))
+		a.float("width", o.Width, 0)
+		a.float("height", o.Height, 0)
+		a.float("rotation", o.Rotation, 0)
+		a.bool("visible", o.Visible, true)
+	}
+	a = append(a, own.UnknownAttrs...)
+	start = element(start.Name.Local, a)
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := encodeProperties(e, own.Properties); err != nil {
+		return err
+	}
+	if own.Ellipse != nil {
+		if err := e.EncodeElement(struct{}{}, element("ellipse", nil)); err != nil {
+			return err
+		}
+	}
+	if own.Point != nil {
+		if err := e.EncodeElement(struct{}{}, element("point", nil)); err != nil {
+			return err
+		}
+	}
+	if err := encodeElements(e, "polygon", own.Polygons); err != nil {
+		return err
+	}
+	if err := encodeElements(e, "polyline", own.PolyLines); err != nil {
+		return err
+	}
+	if own.Text != nil {
+		if err := e.EncodeElement(own.Text, element("text", nil)); err != nil {
+			return err
+		}
+	}
+	if err := own.encodeUnknown(e); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// instanceAttrs returns the attributes declared by a template instance, with the current values of the object.
+func (o *Object) instanceAttrs() attrs {
+	visible := "0"
+	if o.Visible {
+		visible = "1"
+	}
+	values := map[string]string{
+		"id":       strconv.Itoa(o.ID),
+		"template": o.Template,
+		"name":     o.Name,
+		"type":     o.Type,
+		"class":    o.Class,
+		"gid":      strconv.Itoa(o.GID),
+		"x":        strconv.FormatFloat(o.X, 'f', -1, 64),
+		"y":        strconv.FormatFloat(o.Y, 'f', -1, 64),
+		"width":    strconv.FormatFloat(o.Width, 'f', -1, 64),
+		"height":   strconv.FormatFloat(o.Height, 'f', -1, 64),
+		"rotation": strconv.FormatFloat(o.Rotation, 'f', -1, 64),
+		"visible":  visible,
+	}
+	var a attrs
+	for _, attr := range o.attrs {
+		if value, ok := values[attr.Name.Local]; ok {
+			a.add(attr.Name.Local, value)
+		}
+	}
+	return a
+}
+
+// MarshalXML encodes the text, omitting Tiled's default attributes.
+func (t *Text) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	var a attrs
+	if t.FontFamily != "sans-serif" {
+		a.string("fontfamily", t.FontFamily)
+	}
+	if t.PixelSize != 16 {
+		a.int("pixelsize", t.PixelSize)
+	}
+	a.bool("wrap", t.Wrap, false)
+	a.string("color", t.Color)
+	a.bool("bold", t.Bold, false)
+	a.bool("italic", t.Italic, false)
+	a.bool("underline", t.Underline, false)
+	a.bool("strikeout", t.Strikeout, false)
+	a.bool("kerning", t.Kerning, true)
+	if t.HAlign != "left" {
+		a.string("halign", t.HAlign)
+	}
+	if t.VAlign != "top" {
+		a.string("valign", t.VAlign)
+	}
+	start = element(start.Name.Local, a)
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := e.EncodeToken(xml.CharData(t.Value)); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// MarshalXML encodes the image layer and its image.
+func (il *ImageLayer) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	var a attrs
+	a.int("id", il.ID)
+	a.string("name", il.Name)
+	a.string("class", il.Class)
+	a.int("offsetx", il.OffsetX)
+	a.int("offsety", il.OffsetY)
+	a.float("parallaxx", il.ParallaxX, 1)
+	a.float("parallaxy", il.ParallaxY, 1)
+	a.float32("opacity", il.Opacity, 1)
+	a.bool("visible", il.Visible, true)
+	a.bool("locked", il.Locked, false)
+	a.string("tintcolor", il.TintColor)
+	a.bool("repeatx", il.RepeatX, false)
+	a.bool("repeaty", il.RepeatY, false)
+	a = append(a, il.UnknownAttrs...)
+	start = element(start.Name.Local, a)
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := encodeProperties(e, il.Properties); err != nil {
+		return err
+	}
+	if il.Image.Source != "" || il.Image.Data != nil {
+		if err := e.EncodeElement(&il.Image, element("image", nil)); err != nil {
+			return err
+		}
+	}
+	if err := il.encodeUnknown(e); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// MarshalXML encodes the group and its children.
+func (g *Group) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	var a attrs
+	a.int("id", g.ID)
+	a.string("name", g.Name)
+	a.string("class", g.Class)
+	a.int("offsetx", g.OffsetX)
+	a.int("offsety", g.OffsetY)
+	a.float("parallaxx", g.ParallaxX, 1)
+	a.float("parallaxy", g.ParallaxY, 1)
+	a.float32("opacity", g.Opacity, 1)
+	a.bool("visible", g.Visible, true)
+	a.bool("locked", g.Locked, false)
+	a.string("tintcolor", g.TintColor)
+	a = append(a, g.UnknownAttrs...)
+	start = element(start.Name.Local, a)
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := encodeProperties(e, g.Properties); err != nil {
+		return err
+	}
+	if err := encodeLayers(e, g.AllLayers()); err != nil {
+		return err
+	}
+	if err := g.encodeUnknown(e); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
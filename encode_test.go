@@ -0,0 +1,312 @@
+package tmxmap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func roundTrip(t *testing.T, name string, options EncodeOptions) (*Map, *Map) {
+	t.Helper()
+	tmx, err := Load(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := options.Encode(&buf, tmx); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := Decode(&buf)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, buf.String())
+	}
+	return tmx, decoded
+}
+
+func TestEncode(t *testing.T) {
+	for _, options := range []EncodeOptions{{}, {Encoding: "csv"}, {Encoding: "base64", Compression: "zlib"}} {
+		tmx, decoded := roundTrip(t, "assets/external/track1_bg.tmx", options)
+		if decoded.Width != tmx.Width || decoded.TileWidth != tmx.TileWidth || decoded.TileSets[0].Source != tmx.TileSets[0].Source {
+			t.Errorf("%+v: unexpected map: %+v", options, decoded)
+		}
+		if options.Encoding != "" && decoded.Layers[0].Data.Encoding != options.Encoding {
+			t.Errorf("%+v: unexpected encoding: %s", options, decoded.Layers[0].Data.Encoding)
+		}
+		if !reflect.DeepEqual(tileGIDs(decoded.Layers[0].Tiles), tileGIDs(tmx.Layers[0].Tiles)) {
+			t.Errorf("%+v: layer tiles differ after encoding", options)
+		}
+	}
+}
+
+func TestEncodeObjects(t *testing.T) {
+	tmx, decoded := roundTrip(t, "assets/embedded/objects.tmx", EncodeOptions{})
+	if og := decoded.ObjectGroups[0]; og.OffsetX != 4 || og.OffsetY != -2 || og.ParallaxX != 0.5 {
+		t.Errorf("unexpected object group: %+v", og)
+	}
+	for i := range tmx.ObjectGroups[0].Objects {
+		expected, actual := tmx.ObjectGroups[0].Objects[i], decoded.ObjectGroups[0].Objects[i]
+		expected.attrs, actual.attrs = nil, nil
+		if (expected.Tile == nil) != (actual.Tile == nil) || expected.Tile != nil && expected.Tile.ID != actual.Tile.ID {
+			t.Errorf("expected tile %+v, got %+v", expected.Tile, actual.Tile)
+		}
+		expected.Tile, actual.Tile = nil, nil
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("expected %+v, got %+v", expected, actual)
+		}
+	}
+}
+
+func TestEncodeGroups(t *testing.T) {
+	tmx, decoded := roundTrip(t, "assets/embedded/group.tmx", EncodeOptions{})
+	if decoded.Groups[0].Opacity != tmx.Groups[0].Opacity || decoded.Groups[0].OffsetX != tmx.Groups[0].OffsetX {
+		t.Errorf("unexpected group: %+v", decoded.Groups[0])
+	}
+	if !reflect.DeepEqual(tileGIDs(decoded.Groups[0].Layers[0].Tiles), tileGIDs(tmx.Groups[0].Layers[0].Tiles)) {
+		t.Errorf("group layer tiles differ after encoding")
+	}
+	if !decoded.Groups[0].Layers[0].Locked {
+		t.Errorf("locked state should be preserved")
+	}
+	for _, m := range []*Map{tmx, decoded} {
+		if m.Class != "dungeon" || m.TileSets[0].Class != "terrain" || m.Groups[0].Layers[0].Class != "floor" {
+			t.Errorf("unexpected classes: %q, %q, %q", m.Class, m.TileSets[0].Class, m.Groups[0].Layers[0].Class)
+		}
+	}
+	if decoded.Groups[0].Groups[0].ImageLayers[0].Image.Source != "overworld.png" {
+		t.Errorf("unexpected nested image layer: %+v", decoded.Groups[0].Groups[0].ImageLayers[0])
+	}
+}
+
+func TestEncodeCompressionLevel(t *testing.T) {
+	tmx, err := Load("assets/external/track1_bg.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, compression := range []string{"gzip", "zlib", "zstd"} {
+		sizes := map[int]int{}
+		for _, level := range []int{-1, 0, 1, 9} {
+			tmx.CompressionLevel = level
+			var buf bytes.Buffer
+			if err := (EncodeOptions{Encoding: "base64", Compression: compression}).Encode(&buf, tmx); err != nil {
+				t.Fatalf("%s %d: %v", compression, level, err)
+			}
+			sizes[level] = buf.Len()
+			decoded, err := Decode(&buf)
+			if err != nil {
+				t.Fatalf("%s %d: %v", compression, level, err)
+			}
+			if decoded.CompressionLevel != level {
+				t.Errorf("%s %d: unexpected compression level %d", compression, level, decoded.CompressionLevel)
+			}
+			if !reflect.DeepEqual(tileGIDs(decoded.Layers[0].Tiles), tileGIDs(tmx.Layers[0].Tiles)) {
+				t.Errorf("%s %d: layer tiles differ after encoding", compression, level)
+			}
+		}
+		if compression != "zstd" && sizes[0] <= sizes[9] {
+			t.Errorf("%s: level 0 should not compress, got %v", compression, sizes)
+		}
+	}
+}
+
+func TestEncodeBuiltCompressionLevel(t *testing.T) {
+	tileSet := &TileSet{FirstGID: 1, Name: "tiles", TileWidth: 8, TileHeight: 8, Tilecount: 4, Columns: 2}
+	tiles := make([]*TileInfo, 64*64)
+	for i := range tiles {
+		tiles[i] = &TileInfo{ID: GID(i % 2), TileSet: tileSet}
+	}
+	built := &Map{Width: 64, Height: 64, TileWidth: 8, TileHeight: 8, TileSets: []*TileSet{tileSet},
+		Layers: []Layer{{ID: 1, Name: "Ground", Width: 64, Height: 64, Opacity: 1, Visible: true, Tiles: tiles}}}
+
+	var buf strings.Builder
+	if err := (EncodeOptions{Encoding: "base64", Compression: "zlib"}).Encode(&buf, built); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "compressionlevel") {
+		t.Errorf("a map built in code should use the default compression level:\n%s", buf.String())
+	}
+	stored, err := (&Layer{Width: 64, Height: 64, Tiles: tiles}).encodeData("base64", "zlib", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buf.String()) >= len(stored.RawData) {
+		t.Errorf("layer data should be compressed, got %d bytes for the map and %d stored", len(buf.String()), len(stored.RawData))
+	}
+	decoded, err := Decode(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.CompressionLevel != -1 || !reflect.DeepEqual(tileGIDs(decoded.Layers[0].Tiles), tileGIDs(tiles)) {
+		t.Errorf("unexpected decoded map: level %d", decoded.CompressionLevel)
+	}
+}
+
+func TestSave(t *testing.T) {
+	tmx, err := Load("assets/infinite/infinite_csv.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "infinite.tmx")
+	if err := (EncodeOptions{Encoding: "base64", Compression: "zlib"}).Save(name, tmx); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	saved, err := Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !saved.Infinite {
+		t.Errorf("saved map should be infinite")
+	}
+	for origin, tiles := range tmx.Layers[0].ChunkTiles() {
+		if !reflect.DeepEqual(tileGIDs(saved.Layers[0].ChunkTiles()[origin]), tileGIDs(tiles)) {
+			t.Errorf("chunk %v differs after saving", origin)
+		}
+	}
+}
+
+func TestEncodeData(t *testing.T) {
+	tileSet := &TileSet{FirstGID: 1}
+	layer := &Layer{Width: 3, Height: 2, Tiles: []*TileInfo{
+		NilTile,
+		{ID: 0, TileSet: tileSet},
+		{ID: 4, TileSet: tileSet, HorizontalFlip: true},
+		{ID: 7, TileSet: tileSet, VerticalFlip: true, DiagonalFlip: true},
+		{ID: 2, TileSet: tileSet, HorizontalFlip: true, VerticalFlip: true, DiagonalFlip: true},
+		NilTile,
+	}}
+	expected := []GID{0, 1, 5 | horizontalFlip, 8 | verticalFlip | diagonalFlip, 3 | horizontalFlip | verticalFlip | diagonalFlip, 0}
+	for _, encoding := range [][2]string{{"", ""}, {"csv", ""}, {"base64", ""}, {"base64", "gzip"}, {"base64", "zlib"}, {"base64", "zstd"}} {
+		data, err := layer.EncodeData(encoding[0], encoding[1])
+		if err != nil {
+			t.Fatalf("%v: %v", encoding, err)
+		}
+		gids, err := data.decode(data.RawData, data.DataTiles, layer.Width*layer.Height)
+		if err != nil {
+			t.Fatalf("%v: %v", encoding, err)
+		}
+		if !reflect.DeepEqual(gids, expected) {
+			t.Errorf("%v: expected %v, got %v", encoding, expected, gids)
+		}
+	}
+
+	data, err := layer.EncodeData("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := xml.Marshal(&data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := `<tile gid="2147483653"></tile>`; !strings.Contains(string(encoded), s) {
+		t.Errorf("encoded data should contain %s:\n%s", s, encoded)
+	}
+	if _, err := layer.EncodeData("base64", "lzma"); err == nil {
+		t.Errorf("expected an error for an unsupported compression")
+	}
+}
+
+func TestEncodeUnknown(t *testing.T) {
+	tmx, err := Decode(strings.NewReader(`<map width="1" height="1" future="yes">
+ <automapping><rules file="rules.txt"/></automapping>
+ <tileset firstgid="1" name="tiles" tilewidth="8" tileheight="8" tilecount="1" columns="1"/>
+ <layer id="1" name="Ground" width="1" height="1" custom="42"><data encoding="csv">1</data></layer>
+</map>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tmx.UnknownElements) != 1 || tmx.UnknownElements[0].XMLName.Local != "automapping" {
+		t.Fatalf("unexpected unknown elements: %+v", tmx.UnknownElements)
+	}
+
+	var buf strings.Builder
+	if err := tmx.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		`future="yes"`,
+		`<automapping><rules file="rules.txt"/></automapping>`,
+		`custom="42"`,
+	} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("encoded map should contain %s:\n%s", s, buf.String())
+		}
+	}
+}
+
+func TestEncodeEditorSettings(t *testing.T) {
+	tmx, decoded := roundTrip(t, "assets/infinite/infinite_csv.tmx", EncodeOptions{})
+	if tmx.CompressionLevel != -1 || decoded.CompressionLevel != -1 {
+		t.Errorf("unexpected compression levels: %d, %d", tmx.CompressionLevel, decoded.CompressionLevel)
+	}
+	if settings := decoded.EditorSettings; settings == nil || *settings.ChunkSize != (ChunkSize{Width: 4, Height: 4}) {
+		t.Errorf("unexpected editor settings: %+v", settings)
+	}
+
+	tmx.CompressionLevel = 9
+	tmx.EditorSettings.Export = &Export{Target: "out.tmj", Format: "json"}
+	var buf strings.Builder
+	if err := tmx.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{`compressionlevel="9"`, `<export target="out.tmj" format="json"></export>`} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("encoded map should contain %s:\n%s", s, buf.String())
+		}
+	}
+}
+
+func TestEncodeHeader(t *testing.T) {
+	tmx, err := Load("assets/external/track1_bg.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf strings.Builder
+	if err := tmx.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<map version="1.2" tiledversion="1.2.4" `
+	if !strings.HasPrefix(buf.String(), expected) {
+		t.Errorf("encoded map should start with %s:\n%s", expected, buf.String())
+	}
+}
+
+func TestEncodeTemplate(t *testing.T) {
+	tmx, err := Load("assets/templates/track1_bg.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := tmx.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{`<object id="1" template="chest.tx" x="8" y="16"></object>`, `<object id="2" template="chest.tx" name="locked chest" x="24" y="16">`} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("encoded map should contain %s:\n%s", s, buf.String())
+		}
+	}
+	for _, s := range []string{`name="chest"`, `name="gold"`, `gid="9"`} {
+		if strings.Contains(buf.String(), s) {
+			t.Errorf("encoded map should not inline the template %s:\n%s", s, buf.String())
+		}
+	}
+
+	decoded, err := LoadBytes(buf.Bytes(), "assets/templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range tmx.ObjectGroups[0].Objects {
+		expected, actual := tmx.ObjectGroups[0].Objects[i], decoded.ObjectGroups[0].Objects[i]
+		if actual.Name != expected.Name || actual.GID != expected.GID || actual.X != expected.X || !reflect.DeepEqual(actual.Properties, expected.Properties) {
+			t.Errorf("expected %+v, got %+v", expected, actual)
+		}
+	}
+}
@@ -1,3 +1,5 @@
 module github.com/bquenin/tmxmap
 
-go 1.12
+go 1.23
+
+require github.com/klauspost/compress v1.18.0
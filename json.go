@@ -0,0 +1,396 @@
+package tmxmap
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// This file decodes the JSON map format https://doc.mapeditor.org/en/stable/reference/json-map-format/ into the
+// same types as the TMX format. Fields sharing the TMX layout are decoded through their json tags, the hooks below
+// reshape the rest.
+
+// isJSON reports whether the file name has the extension of a JSON map, tileset or template.
+func isJSON(name string) bool {
+	switch path.Ext(name) {
+	case ".json", ".tmj", ".tsj", ".tj":
+		return true
+	}
+	return false
+}
+
+// jsonString returns the string value of a JSON string, number or boolean.
+func jsonString(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+	if raw[0] == '"' {
+		var s string
+		err := json.Unmarshal(raw, &s)
+		return s, err
+	}
+	return string(raw), nil
+}
+
+// jsonImage holds the image fields tilesets, tiles and image layers flatten into their own object.
+type jsonImage struct {
+	Image            string `json:"image"`
+	ImageWidth       int    `json:"imagewidth"`
+	ImageHeight      int    `json:"imageheight"`
+	TransparentColor string `json:"transparentcolor"`
+}
+
+func (ji *jsonImage) image() Image {
+	return Image{
+		Source: ji.Image,
+		Trans:  strings.TrimPrefix(ji.TransparentColor, "#"),
+		Width:  ji.ImageWidth,
+		Height: ji.ImageHeight,
+	}
+}
+
+// jsonLayers holds the layers of a map or group, which are stored in a single array discriminated by their type.
+// The position of each layer in the array is kept as its document order.
+type jsonLayers struct {
+	Layers []json.RawMessage `json:"layers"`
+}
+
+func (jl *jsonLayers) decode(layers *[]Layer, objectGroups *[]ObjectGroup, imageLayers *[]ImageLayer,
+	groups *[]Group) error {
+	for i, raw := range jl.Layers {
+		var kind struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(raw, &kind); err != nil {
+			return err
+		}
+
+		var err error
+		order := int64(i + 1)
+		switch kind.Type {
+		case "tilelayer":
+			*layers = append(*layers, Layer{})
+			layer := &(*layers)[len(*layers)-1]
+			err = json.Unmarshal(raw, layer)
+			layer.order = order
+		case "objectgroup":
+			*objectGroups = append(*objectGroups, ObjectGroup{})
+			objectGroup := &(*objectGroups)[len(*objectGroups)-1]
+			err = json.Unmarshal(raw, objectGroup)
+			objectGroup.order = order
+		case "imagelayer":
+			*imageLayers = append(*imageLayers, ImageLayer{})
+			imageLayer := &(*imageLayers)[len(*imageLayers)-1]
+			err = json.Unmarshal(raw, imageLayer)
+			imageLayer.order = order
+		case "group":
+			*groups = append(*groups, Group{})
+			group := &(*groups)[len(*groups)-1]
+			err = json.Unmarshal(raw, group)
+			group.order = order
+		default:
+			return fmt.Errorf("unsupported layer type: %s", kind.Type)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnmarshalJSON applies Tiled's defaults and decodes the map version, which older versions of Tiled store as a
+// number, and the layers.
+func (m *Map) UnmarshalJSON(data []byte) error {
+	type tileMap Map
+	v := struct {
+		*tileMap
+		Version json.RawMessage `json:"version"`
+		jsonLayers
+	}{tileMap: &tileMap{CompressionLevel: -1, unmarshalled: true}}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*m = Map(*v.tileMap)
+	var err error
+	if m.Version, err = jsonString(v.Version); err != nil {
+		return err
+	}
+	return v.jsonLayers.decode(&m.Layers, &m.ObjectGroups, &m.ImageLayers, &m.Groups)
+}
+
+// UnmarshalJSON decodes the property value, which is stored with its JSON type.
+func (p *Property) UnmarshalJSON(data []byte) error {
+	type property Property
+	v := struct {
+		*property
+		Value json.RawMessage `json:"value"`
+	}{property: (*property)(p)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	var err error
+	p.Value, err = jsonString(v.Value)
+	return err
+}
+
+// UnmarshalJSON applies Tiled's defaults and decodes the tileset image.
+func (ts *TileSet) UnmarshalJSON(data []byte) error {
+	type tileSet TileSet
+	v := struct {
+		*tileSet
+		jsonImage
+	}{tileSet: (*tileSet)(ts)}
+	if ts.ObjectAlignment == "" {
+		ts.ObjectAlignment = "unspecified"
+	}
+	if ts.FillMode == "" {
+		ts.FillMode = "stretch"
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	if v.jsonImage.Image != "" {
+		image := v.image()
+		ts.Image = &image
+	}
+	return nil
+}
+
+// UnmarshalJSON applies Tiled's defaults and decodes the image of a tile from a collection tileset.
+func (t *Tile) UnmarshalJSON(data []byte) error {
+	type tile Tile
+	v := struct {
+		*tile
+		jsonImage
+	}{tile: &tile{Probability: 1}}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*t = Tile(*v.tile)
+	t.Image = v.image()
+	return nil
+}
+
+// UnmarshalJSON applies Tiled's defaults and decodes the layer data, either an array of GIDs or a base64 string.
+func (l *Layer) UnmarshalJSON(data []byte) error {
+	type layer Layer
+	v := struct {
+		*layer
+		Data        json.RawMessage `json:"data"`
+		Encoding    string          `json:"encoding"`
+		Compression string          `json:"compression"`
+		Chunks      []struct {
+			Chunk
+			Data json.RawMessage `json:"data"`
+		} `json:"chunks"`
+	}{layer: &layer{Opacity: 1, Visible: true, ParallaxX: 1, ParallaxY: 1}}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*l = Layer(*v.layer)
+	l.Data = Data{Encoding: v.Encoding, Compression: v.Compression}
+	if l.Data.Encoding == "" {
+		l.Data.Encoding = "csv"
+	}
+
+	var err error
+	if l.Data.RawData, err = l.Data.jsonRawData(v.Data); err != nil {
+		return err
+	}
+	for _, chunk := range v.Chunks {
+		if chunk.RawData, err = l.Data.jsonRawData(chunk.Data); err != nil {
+			return err
+		}
+		l.Data.Chunk = append(l.Data.Chunk, chunk.Chunk)
+	}
+	return nil
+}
+
+// jsonRawData converts JSON layer data to the raw data of the TMX encoding. GID arrays become CSV.
+func (d *Data) jsonRawData(raw json.RawMessage) ([]byte, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	if d.Encoding == "base64" {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, err
+		}
+		return []byte(s), nil
+	}
+
+	var gids []GID
+	if err := json.Unmarshal(raw, &gids); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	for i, gid := range gids {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(strconv.FormatUint(uint64(gid), 10))
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON applies Tiled's defaults for the fields missing from the object.
+func (og *ObjectGroup) UnmarshalJSON(data []byte) error {
+	type objectGroup ObjectGroup
+	v := objectGroup{Opacity: 1, Visible: true, ParallaxX: 1, ParallaxY: 1, DrawOrder: "topdown"}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*og = ObjectGroup(v)
+	return nil
+}
+
+// UnmarshalJSON applies Tiled's defaults and decodes the object shape.
+func (o *Object) UnmarshalJSON(data []byte) error {
+	type object Object
+	v := struct {
+		*object
+		Ellipse  bool    `json:"ellipse"`
+		Point    bool    `json:"point"`
+		Polygon  []Point `json:"polygon"`
+		PolyLine []Point `json:"polyline"`
+	}{object: &object{Visible: true}}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	attrs, err := jsonAttrs(data)
+	if err != nil {
+		return err
+	}
+
+	*o = Object(*v.object)
+	o.attrs = attrs
+	o.resolveType()
+	if v.Ellipse {
+		o.Ellipse = &struct{}{}
+	}
+	if v.Point {
+		o.Point = &struct{}{}
+	}
+	if v.Polygon != nil {
+		o.Polygons = []Polygon{{Points: formatPoints(v.Polygon)}}
+	}
+	if v.PolyLine != nil {
+		o.PolyLines = []PolyLine{{Points: formatPoints(v.PolyLine)}}
+	}
+	return nil
+}
+
+// jsonObjectAttrs are the fields of a JSON object matching the attributes of a TMX object.
+var jsonObjectAttrs = map[string]bool{
+	"id": true, "name": true, "type": true, "class": true, "x": true, "y": true, "width": true, "height": true,
+	"rotation": true, "gid": true, "visible": true, "template": true,
+}
+
+// jsonAttrs returns the fields of a JSON object matching TMX object attributes, used to override template defaults.
+func jsonAttrs(data []byte) ([]xml.Attr, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	var attrs []xml.Attr
+	for name, raw := range fields {
+		if !jsonObjectAttrs[name] {
+			continue
+		}
+		value, err := jsonString(raw)
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, xml.Attr{Name: xml.Name{Local: name}, Value: value})
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].Name.Local < attrs[j].Name.Local })
+	return attrs, nil
+}
+
+// formatPoints formats points the way the TMX format stores them.
+func formatPoints(points []Point) string {
+	coords := make([]string, len(points))
+	for i, p := range points {
+		coords[i] = strconv.FormatFloat(p.X, 'f', -1, 64) + "," + strconv.FormatFloat(p.Y, 'f', -1, 64)
+	}
+	return strings.Join(coords, " ")
+}
+
+// UnmarshalJSON applies Tiled's defaults for the fields missing from the object.
+func (t *Text) UnmarshalJSON(data []byte) error {
+	type text Text
+	v := text{
+		FontFamily: "sans-serif",
+		PixelSize:  16,
+		Kerning:    true,
+		HAlign:     "left",
+		VAlign:     "top",
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*t = Text(v)
+	return nil
+}
+
+// UnmarshalJSON applies Tiled's defaults and decodes the layer image.
+func (il *ImageLayer) UnmarshalJSON(data []byte) error {
+	type imageLayer ImageLayer
+	v := struct {
+		*imageLayer
+		jsonImage
+	}{imageLayer: &imageLayer{Opacity: 1, Visible: true, ParallaxX: 1, ParallaxY: 1}}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*il = ImageLayer(*v.imageLayer)
+	il.Image = v.image()
+	return nil
+}
+
+// UnmarshalJSON applies Tiled's defaults and decodes the nested layers.
+func (g *Group) UnmarshalJSON(data []byte) error {
+	type group Group
+	v := struct {
+		*group
+		jsonLayers
+	}{group: &group{Opacity: 1, Visible: true, ParallaxX: 1, ParallaxY: 1}}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*g = Group(*v.group)
+	return v.jsonLayers.decode(&g.Layers, &g.ObjectGroups, &g.ImageLayers, &g.Groups)
+}
+
+// UnmarshalJSON decodes the wang ID, which is stored as an array of color indexes.
+func (wt *WangTile) UnmarshalJSON(data []byte) error {
+	type wangTile WangTile
+	v := struct {
+		*wangTile
+		WangID []int `json:"wangid"`
+	}{wangTile: (*wangTile)(wt)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	ids := make([]string, len(v.WangID))
+	for i, id := range v.WangID {
+		ids[i] = strconv.Itoa(id)
+	}
+	wt.WangID = strings.Join(ids, ",")
+	return nil
+}
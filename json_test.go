@@ -0,0 +1,144 @@
+package tmxmap
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestLoadJSON(t *testing.T) {
+	tmx, err := Load("assets/external/track1_bg.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	json, err := LoadJSON("assets/json/track1_bg.tmj")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if json.Version != "1.10" || json.Width != 32 || json.Height != 6 {
+		t.Errorf("unexpected map header: %+v", json)
+	}
+	if json.TileSets[0].Image == nil || json.TileSets[0].Image.Image == nil {
+		t.Fatal("external JSON tileset image should be loaded")
+	}
+	if len(json.Layers[0].Tiles) != len(tmx.Layers[0].Tiles) {
+		t.Fatalf("expected %d tiles, got %d", len(tmx.Layers[0].Tiles), len(json.Layers[0].Tiles))
+	}
+	for i, tile := range tmx.Layers[0].Tiles {
+		got := json.Layers[0].Tiles[i]
+		if got.ID != tile.ID || got.HorizontalFlip != tile.HorizontalFlip || got.Nil != tile.Nil {
+			t.Errorf("tile %d: expected %+v, got %+v", i, tile, got)
+		}
+	}
+}
+
+func TestLoadInfiniteJSON(t *testing.T) {
+	tmx, err := Load("assets/infinite/infinite_csv.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	json, err := LoadJSON("assets/json/infinite.tmj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !json.Infinite || len(json.Layers) != 2 {
+		t.Fatalf("unexpected map: %+v", json)
+	}
+	expected := tmx.Layers[0].ChunkTiles()
+	for _, layer := range json.Layers {
+		chunks := layer.ChunkTiles()
+		if len(chunks) != len(expected) {
+			t.Fatalf("%s: expected %d chunks, got %d", layer.Name, len(expected), len(chunks))
+		}
+		for origin, tiles := range expected {
+			if !reflect.DeepEqual(tileGIDs(chunks[origin]), tileGIDs(tiles)) {
+				t.Errorf("%s: chunk %v differs from the TMX map", layer.Name, origin)
+			}
+		}
+	}
+	if json.Layers[1].Data.Encoding != "base64" || json.Layers[1].Data.Compression != "zlib" {
+		t.Errorf("unexpected encoding: %+v", json.Layers[1].Data.Encoding)
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	file, err := os.Open("assets/json/objects.tmj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	tmx, err := DecodeJSON(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tmx.Version != "1.2" {
+		t.Errorf("expected numeric version 1.2, got %q", tmx.Version)
+	}
+	ts := tmx.TileSets[0]
+	if ts.Image == nil || ts.Image.Source != "../external/track1_bg.png" || ts.Image.Width != 128 {
+		t.Errorf("unexpected tileset image: %+v", ts.Image)
+	}
+	if !ts.Tiles[0].IsAnimated() || ts.Tiles[0].Animation[1].TileID != 1 {
+		t.Errorf("unexpected tile animation: %+v", ts.Tiles[0].Animation)
+	}
+
+	layer := tmx.Layers[0]
+	if tile := layer.Tiles[3]; tile.ID != 0 || !tile.HorizontalFlip {
+		t.Errorf("expected flipped tile 0, got %+v", tile)
+	}
+	if !layer.Tiles[2].Nil {
+		t.Errorf("expected nil tile, got %+v", layer.Tiles[2])
+	}
+	if speed, _ := layer.Property("speed"); speed != "1.5" {
+		t.Errorf("expected speed 1.5, got %q", speed)
+	}
+	if solid, _ := layer.Property("solid"); solid != "true" {
+		t.Errorf("expected solid true, got %q", solid)
+	}
+
+	objects := tmx.ObjectGroups[0].Objects
+	kinds := []ObjectKind{EllipseObject, PolygonObject, TextObject}
+	for i, kind := range kinds {
+		if objects[i].Kind() != kind {
+			t.Errorf("object %d: expected kind %d, got %d", i, kind, objects[i].Kind())
+		}
+	}
+	if objects[1].Polygons[0].Points != "0,0 16,0 16,16" {
+		t.Errorf("unexpected polygon points: %s", objects[1].Polygons[0].Points)
+	}
+	if objects[0].Rotation != 0 || objects[2].Rotation != 15.5 {
+		t.Errorf("unexpected rotations: %v, %v", objects[0].Rotation, objects[2].Rotation)
+	}
+	text := objects[2].Text
+	if text.Value != "Hello World" || !text.Wrap || text.HAlign != "center" || text.PixelSize != 16 {
+		t.Errorf("unexpected text: %+v", text)
+	}
+
+	group := tmx.Groups[0]
+	if group.Visible || len(group.ImageLayers) != 1 || group.ImageLayers[0].Opacity != 0.5 {
+		t.Errorf("unexpected group: %+v", group)
+	}
+	if group.ImageLayers[0].Image.Source != "../external/track1_bg.png" {
+		t.Errorf("unexpected image layer source: %s", group.ImageLayers[0].Image.Source)
+	}
+}
+
+func TestLoadDetectsFormat(t *testing.T) {
+	tmx, err := Load("assets/json/track1_bg.tmj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tmx.Layers[0].Tiles) != 32*6 {
+		t.Errorf("expected %d tiles, got %d", 32*6, len(tmx.Layers[0].Tiles))
+	}
+
+	if _, err := Load("assets/external/track1_bg.tmx"); err != nil {
+		t.Error(err)
+	}
+	if _, err := LoadTMX("assets/json/track1_bg.tmj"); err == nil {
+		t.Error("expected an error loading a JSON map as TMX")
+	}
+}
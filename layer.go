@@ -0,0 +1,329 @@
+package tmxmap
+
+import (
+	"cmp"
+	"errors"
+	"image"
+	"image/color"
+	"iter"
+	"math"
+	"slices"
+)
+
+// LayerLike is implemented by the layer types a map or group holds: *Layer, *ObjectGroup, *ImageLayer and *Group.
+type LayerLike interface {
+	Property(name string) (string, bool)
+	documentOrder() int64
+	composition() (Composition, error)
+}
+
+func (l *Layer) documentOrder() int64        { return l.order }
+func (og *ObjectGroup) documentOrder() int64 { return og.order }
+func (il *ImageLayer) documentOrder() int64  { return il.order }
+func (g *Group) documentOrder() int64        { return g.order }
+
+// AllLayers returns the layers of the map in document order, which is the order they are rendered in, from bottom
+// to top. Layers nested in groups are returned by Group.AllLayers. Layers added after decoding come last.
+func (m *Map) AllLayers() []LayerLike {
+	return sortLayers(m.Layers, m.ObjectGroups, m.ImageLayers, m.Groups)
+}
+
+// AllLayers returns the layers of the group in document order.
+func (g *Group) AllLayers() []LayerLike {
+	return sortLayers(g.Layers, g.ObjectGroups, g.ImageLayers, g.Groups)
+}
+
+// Layer returns the first tile layer with the given name, looking into groups after the top-level layers.
+func (m *Map) Layer(name string) (*Layer, bool) {
+	var found *Layer
+	m.walkLayers(m.Layers, m.Groups, func(l *Layer) {
+		if found == nil && l.Name == name {
+			found = l
+		}
+	})
+	return found, found != nil
+}
+
+// ObjectGroup returns the first object group with the given name, looking into groups after the top-level layers.
+func (m *Map) ObjectGroup(name string) (*ObjectGroup, bool) {
+	var found *ObjectGroup
+	m.walkObjectGroups(m.ObjectGroups, m.Groups, func(og *ObjectGroup) error {
+		if found == nil && og.Name == name {
+			found = og
+		}
+		return nil
+	})
+	return found, found != nil
+}
+
+// Composition holds the rendering state of a layer combined with the one of the groups holding it.
+type Composition struct {
+	// OffsetX and OffsetY are the sums of the offsets of the layer and its groups, in pixels.
+	OffsetX, OffsetY int
+
+	// Opacity is the opacity of the layer multiplied by the opacity of its groups.
+	Opacity float32
+
+	// Visible reports whether the layer and all its groups are visible.
+	Visible bool
+
+	// Tint is the tint color of the layer multiplied by the tint colors of its groups, opaque white when none is set.
+	Tint color.RGBA
+}
+
+// Compose returns the offset, opacity, visibility and tint of the layer once combined with the groups holding it,
+// the way Tiled renders nested layers. It fails if the layer is not part of the map or a tint color is invalid.
+func (m *Map) Compose(l LayerLike) (Composition, error) {
+	groups, ok := groupPath(m.AllLayers(), l)
+	if !ok {
+		return Composition{}, errors.New("layer is not part of the map")
+	}
+	c, err := l.composition()
+	if err != nil {
+		return Composition{}, err
+	}
+	for _, g := range groups {
+		parent, err := g.composition()
+		if err != nil {
+			return Composition{}, err
+		}
+		c.OffsetX += parent.OffsetX
+		c.OffsetY += parent.OffsetY
+		c.Opacity *= parent.Opacity
+		c.Visible = c.Visible && parent.Visible
+		c.Tint = color.RGBA{
+			R: uint8(uint16(c.Tint.R) * uint16(parent.Tint.R) / 0xff),
+			G: uint8(uint16(c.Tint.G) * uint16(parent.Tint.G) / 0xff),
+			B: uint8(uint16(c.Tint.B) * uint16(parent.Tint.B) / 0xff),
+			A: uint8(uint16(c.Tint.A) * uint16(parent.Tint.A) / 0xff),
+		}
+	}
+	return c, nil
+}
+
+// groupPath returns the groups leading to the target layer, from the outermost one.
+func groupPath(layers []LayerLike, target LayerLike) ([]*Group, bool) {
+	for _, l := range layers {
+		if l == target {
+			return nil, true
+		}
+		if g, ok := l.(*Group); ok {
+			if path, ok := groupPath(g.AllLayers(), target); ok {
+				return append([]*Group{g}, path...), true
+			}
+		}
+	}
+	return nil, false
+}
+
+func (l *Layer) composition() (Composition, error) {
+	tint, err := l.TintRGBA()
+	return Composition{l.OffsetX, l.OffsetY, l.Opacity, l.Visible, tint}, err
+}
+
+func (og *ObjectGroup) composition() (Composition, error) {
+	tint, err := og.TintRGBA()
+	return Composition{og.OffsetX, og.OffsetY, og.Opacity, og.Visible, tint}, err
+}
+
+func (il *ImageLayer) composition() (Composition, error) {
+	tint, err := il.TintRGBA()
+	return Composition{il.OffsetX, il.OffsetY, il.Opacity, il.Visible, tint}, err
+}
+
+func (g *Group) composition() (Composition, error) {
+	tint, err := g.TintRGBA()
+	return Composition{g.OffsetX, g.OffsetY, g.Opacity, g.Visible, tint}, err
+}
+
+func sortLayers(layers []Layer, objectGroups []ObjectGroup, imageLayers []ImageLayer, groups []Group) []LayerLike {
+	all := make([]LayerLike, 0, len(layers)+len(objectGroups)+len(imageLayers)+len(groups))
+	for i := range layers {
+		all = append(all, &layers[i])
+	}
+	for i := range objectGroups {
+		all = append(all, &objectGroups[i])
+	}
+	for i := range imageLayers {
+		all = append(all, &imageLayers[i])
+	}
+	for i := range groups {
+		all = append(all, &groups[i])
+	}
+
+	order := func(l LayerLike) int64 {
+		if l.documentOrder() == 0 {
+			return math.MaxInt64
+		}
+		return l.documentOrder()
+	}
+	slices.SortStableFunc(all, func(a, b LayerLike) int { return cmp.Compare(order(a), order(b)) })
+	return all
+}
+
+// TileAt returns the tile at the given column and row. It returns false if the coordinates are out of range or
+// the layer tiles have not been decoded.
+func (l *Layer) TileAt(x, y int) (*TileInfo, bool) {
+	if x < 0 || y < 0 || x >= l.Width || y >= l.Height {
+		return nil, false
+	}
+	i := y*l.Width + x
+	if i >= len(l.Tiles) {
+		return nil, false
+	}
+	return l.Tiles[i], true
+}
+
+// TilesInRegion returns the tiles of the layer whose bounding box intersects the given rectangle, in pixels from
+// the map origin, in row-major order. Layer offsets are not applied. It suits downsampling a layer for minimaps or
+// previews, by picking the dominant tile of each region. Only the decoded tiles of finite maps are returned.
+func (m *Map) TilesInRegion(l *Layer, r image.Rectangle) []*TileInfo {
+	if r.Empty() {
+		return nil
+	}
+
+	// The tiles at the corners of the region bound the candidates, give or take one tile, as isometric and
+	// staggered cells are sheared or shifted relative to the pixels.
+	var area image.Rectangle
+	corners := []image.Point{r.Min, {r.Max.X - 1, r.Min.Y}, {r.Min.X, r.Max.Y - 1}, r.Max.Sub(image.Pt(1, 1))}
+	for i, corner := range corners {
+		x, y := m.PixelToTile(corner.X, corner.Y)
+		cell := image.Rect(x-1, y-1, x+2, y+2)
+		if i == 0 {
+			area = cell
+		} else {
+			area = area.Union(cell)
+		}
+	}
+	area = area.Intersect(image.Rect(0, 0, l.Width, l.Height))
+
+	var tiles []*TileInfo
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			px, py := m.TileToPixel(x, y)
+			if !image.Rect(px, py, px+m.TileWidth, py+m.TileHeight).Overlaps(r) {
+				continue
+			}
+			if t, ok := l.TileAt(x, y); ok {
+				tiles = append(tiles, t)
+			}
+		}
+	}
+	return tiles
+}
+
+// ForEachTile calls fn for each decoded tile of the layer in row-major order, until fn returns false. Tiles of
+// infinite maps are visited chunk by chunk, with coordinates relative to the map origin.
+func (l *Layer) ForEachTile(fn func(x, y int, t *TileInfo) bool) {
+	for i, t := range l.Tiles {
+		if !fn(i%l.Width, i/l.Width, t) {
+			return
+		}
+	}
+	for _, chunk := range l.Data.Chunk {
+		for i, t := range chunk.Tiles {
+			if !fn(chunk.X+i%chunk.Width, chunk.Y+i/chunk.Width, t) {
+				return
+			}
+		}
+	}
+}
+
+// Bounds returns the area of the map, in tiles. For infinite maps, it is the union of the chunks of all the layers,
+// which may start at negative coordinates.
+func (m *Map) Bounds() image.Rectangle {
+	if !m.Infinite {
+		return image.Rect(0, 0, m.Width, m.Height)
+	}
+	var bounds image.Rectangle
+	m.walkLayers(m.Layers, m.Groups, func(l *Layer) {
+		for _, chunk := range l.Data.Chunk {
+			bounds = bounds.Union(image.Rect(chunk.X, chunk.Y, chunk.X+chunk.Width, chunk.Y+chunk.Height))
+		}
+	})
+	return bounds
+}
+
+// IterateTiles visits the tiles of the layer following the map render order. Maps without a render order are
+// visited right-down.
+func (m *Map) IterateTiles(layer *Layer, fn func(x, y int, t *TileInfo)) {
+	x0, dx := 0, 1
+	y0, dy := 0, 1
+	switch m.RenderOrder {
+	case "right-up":
+		y0, dy = layer.Height-1, -1
+	case "left-down":
+		x0, dx = layer.Width-1, -1
+	case "left-up":
+		x0, dx = layer.Width-1, -1
+		y0, dy = layer.Height-1, -1
+	}
+	for y := y0; y >= 0 && y < layer.Height; y += dy {
+		for x := x0; x >= 0 && x < layer.Width; x += dx {
+			if t, ok := layer.TileAt(x, y); ok {
+				fn(x, y, t)
+			}
+		}
+	}
+}
+
+// TilesSeq returns an iterator over the tiles of the layer and their coordinates, chunk origins included for
+// infinite maps. Layers loaded with LoadOptions.SkipTiles are decoded one chunk at a time and their tiles resolved
+// as they are visited, following the options the map was loaded with: GIDs ignored with LoadOptions.IgnoreInvalidGID
+// are added to Map.InvalidGIDs as they are visited. The returned function reports the error that stopped the
+// iteration, if any.
+func (m *Map) TilesSeq(layer *Layer) (iter.Seq2[image.Point, *TileInfo], func() error) {
+	var err error
+	seq := func(yield func(image.Point, *TileInfo) bool) {
+		err = nil
+		if len(layer.Data.Chunk) == 0 {
+			_, err = m.yieldTiles(layer, layer.Data.RawData, layer.Data.DataTiles, layer.Tiles,
+				image.Rect(0, 0, layer.Width, layer.Height), yield)
+			return
+		}
+		for i := range layer.Data.Chunk {
+			chunk := &layer.Data.Chunk[i]
+			var more bool
+			more, err = m.yieldTiles(layer, chunk.RawData, chunk.DataTiles, chunk.Tiles,
+				image.Rect(chunk.X, chunk.Y, chunk.X+chunk.Width, chunk.Y+chunk.Height), yield)
+			if !more {
+				return
+			}
+		}
+	}
+	return seq, func() error { return err }
+}
+
+// yieldTiles yields the tiles of the layer data or of one of its chunks, covering area. Tiles not decoded yet are
+// resolved from the data, invalid GIDs being reported at their index within it. It returns false once the
+// iteration stops.
+func (m *Map) yieldTiles(layer *Layer, rawData []byte, dataTiles []DataTile, tiles []*TileInfo, area image.Rectangle,
+	yield func(image.Point, *TileInfo) bool) (bool, error) {
+	at := func(i int) image.Point {
+		return area.Min.Add(image.Pt(i%area.Dx(), i/area.Dx()))
+	}
+	if tiles != nil {
+		for i, t := range tiles {
+			if !yield(at(i), t) {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+
+	gids, err := m.decodeData(layer, rawData, dataTiles, area.Dx()*area.Dy())
+	if err != nil {
+		return false, err
+	}
+	ld := &loader{LoadOptions: m.options}
+	for i, gid := range gids {
+		t, err := m.resolveGID(ld, layer.Name, i, gid)
+		if err != nil {
+			return false, err
+		}
+		if !yield(at(i), t) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
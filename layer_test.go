@@ -0,0 +1,380 @@
+package tmxmap
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTileAt(t *testing.T) {
+	tmx, err := Load("assets/external/track1_bg.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer := &tmx.Layers[0]
+	tile, ok := layer.TileAt(3, 2)
+	if !ok {
+		t.Fatalf("expected a tile at 3,2")
+	}
+	if tile != layer.Tiles[2*layer.Width+3] {
+		t.Errorf("unexpected tile at 3,2")
+	}
+	for _, p := range [][2]int{{-1, 0}, {0, -1}, {layer.Width, 0}, {0, layer.Height}} {
+		if _, ok := layer.TileAt(p[0], p[1]); ok {
+			t.Errorf("expected no tile at %d,%d", p[0], p[1])
+		}
+	}
+	if _, ok := (&Layer{Width: 2, Height: 2}).TileAt(0, 0); ok {
+		t.Errorf("expected no tile in an undecoded layer")
+	}
+}
+
+func TestTilesInRegion(t *testing.T) {
+	tests := []struct {
+		orientation string
+		region      image.Rectangle
+		expected    []GID
+	}{
+		{"orthogonal", image.Rect(8, 8, 40, 24), []GID{0, 1, 4, 5}},
+		{"orthogonal", image.Rect(32, 16, 64, 32), []GID{5}},
+		{"orthogonal", image.Rect(-8, -8, 0, 0), nil},
+		{"isometric", image.Rect(40, 4, 42, 6), []GID{0}},
+		{"isometric", image.Rect(0, 12, 64, 14), []GID{0, 1, 4}},
+	}
+	for _, test := range tests {
+		tmx, err := Decode(strings.NewReader(`<map orientation="` + test.orientation + `" width="4" height="3" tilewidth="32" tileheight="16">
+ <tileset firstgid="1" name="tiles" tilewidth="32" tileheight="16" tilecount="12" columns="4"/>
+ <layer name="Ground" width="4" height="3"><data encoding="csv">1,2,3,4,5,6,7,8,9,10,11,12</data></layer>
+</map>`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []GID
+		for _, tile := range tmx.TilesInRegion(&tmx.Layers[0], test.region) {
+			ids = append(ids, tile.ID)
+		}
+		if !reflect.DeepEqual(ids, test.expected) {
+			t.Errorf("%s %v: expected %v, got %v", test.orientation, test.region, test.expected, ids)
+		}
+	}
+}
+
+func TestIterateTiles(t *testing.T) {
+	layer := &Layer{Width: 2, Height: 2, Tiles: []*TileInfo{{ID: 0}, {ID: 1}, {ID: 2}, {ID: 3}}}
+	tests := []struct {
+		renderOrder string
+		expected    []GID
+	}{
+		{"", []GID{0, 1, 2, 3}},
+		{"right-down", []GID{0, 1, 2, 3}},
+		{"right-up", []GID{2, 3, 0, 1}},
+		{"left-down", []GID{1, 0, 3, 2}},
+		{"left-up", []GID{3, 2, 1, 0}},
+	}
+	for _, test := range tests {
+		var visited []GID
+		m := &Map{RenderOrder: test.renderOrder}
+		m.IterateTiles(layer, func(x, y int, tile *TileInfo) {
+			if GID(y*layer.Width+x) != tile.ID {
+				t.Errorf("%s: tile %d visited at %d,%d", test.renderOrder, tile.ID, x, y)
+			}
+			visited = append(visited, tile.ID)
+		})
+		if !reflect.DeepEqual(visited, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.renderOrder, test.expected, visited)
+		}
+	}
+}
+
+func TestTilesSeq(t *testing.T) {
+	eager, err := Load("assets/infinite/infinite_csv.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lazy, err := LoadOptions{SkipTiles: true}.Load("assets/infinite/infinite_csv.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lazy.Layers[0].Data.Chunk[0].Tiles != nil {
+		t.Fatal("chunk tiles should not be decoded")
+	}
+
+	expected := map[image.Point]*TileInfo{}
+	eager.Layers[0].ForEachTile(func(x, y int, tile *TileInfo) bool {
+		expected[image.Pt(x, y)] = tile
+		return true
+	})
+	for _, tmx := range []*Map{eager, lazy} {
+		tiles, tilesErr := tmx.TilesSeq(&tmx.Layers[0])
+		n := 0
+		for p, tile := range tiles {
+			if e := expected[p]; e == nil || tile.ID != e.ID || tile.Nil != e.Nil || tile.HorizontalFlip != e.HorizontalFlip {
+				t.Errorf("tile %v: expected %+v, got %+v", p, e, tile)
+			}
+			n++
+		}
+		if err := tilesErr(); err != nil {
+			t.Fatal(err)
+		}
+		if n != len(expected) {
+			t.Errorf("expected %d tiles, got %d", len(expected), n)
+		}
+	}
+}
+
+func TestTilesSeqOptions(t *testing.T) {
+	data := `<map width="4" height="2" tilewidth="8" tileheight="8" infinite="1">
+ <tileset firstgid="5" name="tiles" tilewidth="8" tileheight="8" tilecount="4" columns="2"/>
+ <layer name="Ground" width="4" height="2"><data encoding="csv">
+  <chunk x="0" y="0" width="2" height="2">5,6,7,8</chunk>
+  <chunk x="2" y="0" width="2" height="2">5,2,7,8</chunk>
+ </data></layer>
+</map>`
+	tmx, err := LoadOptions{SkipTiles: true}.Decode(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tiles, tilesErr := tmx.TilesSeq(&tmx.Layers[0])
+	for range tiles {
+	}
+	var invalid *InvalidGIDError
+	if !errors.As(tilesErr(), &invalid) || invalid.GID != 2 || invalid.Index != 1 {
+		t.Errorf("expected an invalid GID at index 1 of the chunk, got %v", tilesErr())
+	}
+
+	tmx, err = LoadOptions{SkipTiles: true, IgnoreInvalidGID: true}.Decode(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tiles, tilesErr = tmx.TilesSeq(&tmx.Layers[0])
+	for p, tile := range tiles {
+		if p == image.Pt(3, 0) && !tile.Nil {
+			t.Errorf("invalid GID should be replaced by a nil tile, got %+v", tile)
+		}
+	}
+	if err := tilesErr(); err != nil {
+		t.Fatal(err)
+	}
+	if len(tmx.InvalidGIDs) != 1 || tmx.InvalidGIDs[0].Index != 1 {
+		t.Errorf("unexpected invalid GIDs: %v", tmx.InvalidGIDs)
+	}
+
+	zlib := "eJzF0EkKgDAMBdDUeZ7HnUfxaB7Vo/jFFoJULSgYeItfkoZWEJEFNogfOODSUR74EEghRCzzs1DOxgYzOqpPVwmkkLGcQ8HOTGdKDX5PBTU00D7oTv1f5B6GizfthT+eYFH53P82jze75f4Z1qv+t3kDe18INA=="
+	sniffed, err := LoadOptions{SkipTiles: true}.Decode(strings.NewReader(layerMap(32, 6, `<data encoding="base64">`+zlib+`</data>`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tiles, tilesErr = sniffed.TilesSeq(&sniffed.Layers[0])
+	for range tiles {
+	}
+	if err := tilesErr(); err != nil {
+		t.Fatal(err)
+	}
+	if len(sniffed.Warnings) != 1 {
+		t.Errorf("expected a warning for the sniffed compression, got %v", sniffed.Warnings)
+	}
+}
+
+func TestForEachTile(t *testing.T) {
+	tmx, err := Load("assets/external/track1_bg.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer := &tmx.Layers[0]
+	visited := 0
+	layer.ForEachTile(func(x, y int, tile *TileInfo) bool {
+		if expected, _ := layer.TileAt(x, y); tile != expected {
+			t.Errorf("tile %d,%d does not match TileAt", x, y)
+		}
+		visited++
+		return true
+	})
+	if visited != layer.Width*layer.Height {
+		t.Errorf("expected %d tiles, visited %d", layer.Width*layer.Height, visited)
+	}
+
+	visited = 0
+	layer.ForEachTile(func(x, y int, tile *TileInfo) bool {
+		visited++
+		return visited < 3
+	})
+	if visited != 3 {
+		t.Errorf("iteration should stop when fn returns false, visited %d", visited)
+	}
+
+	infinite, err := Load("assets/infinite/infinite_csv.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var first, last image.Point
+	infinite.Layers[0].ForEachTile(func(x, y int, tile *TileInfo) bool {
+		if tile.ID == 0 && !tile.Nil {
+			first = image.Pt(x, y)
+		}
+		if tile.ID == 30 {
+			last = image.Pt(x, y)
+		}
+		return true
+	})
+	if first != image.Pt(-4, 0) || last != image.Pt(2, 3) {
+		t.Errorf("unexpected chunk tile coordinates: %v, %v", first, last)
+	}
+}
+
+func TestMapBounds(t *testing.T) {
+	tmx, err := Load("assets/external/track1_bg.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bounds := tmx.Bounds(); bounds != image.Rect(0, 0, 32, 6) {
+		t.Errorf("unexpected bounds: %v", bounds)
+	}
+
+	infinite, err := Load("assets/infinite/infinite_csv.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bounds := infinite.Bounds(); bounds != image.Rect(-4, 0, 4, 4) {
+		t.Errorf("unexpected infinite bounds: %v", bounds)
+	}
+	infinite.Groups = []Group{{Layers: []Layer{{Data: Data{Chunk: []Chunk{{X: 16, Y: -16, Width: 16, Height: 16}}}}}}}
+	if bounds := infinite.Bounds(); bounds != image.Rect(-4, -16, 32, 4) {
+		t.Errorf("bounds should include the chunks of nested layers, got %v", bounds)
+	}
+}
+
+func layerNames(layers []LayerLike) []string {
+	names := make([]string, len(layers))
+	for i, layer := range layers {
+		switch layer := layer.(type) {
+		case *Layer:
+			names[i] = layer.Name
+		case *ObjectGroup:
+			names[i] = layer.Name
+		case *ImageLayer:
+			names[i] = layer.Name
+		case *Group:
+			names[i] = layer.Name
+		}
+	}
+	return names
+}
+
+func TestAllLayers(t *testing.T) {
+	tmx, err := Decode(strings.NewReader(`<map width="1" height="1" tilewidth="8" tileheight="8">
+ <imagelayer id="1" name="Sky"/>
+ <layer id="2" name="Ground" width="1" height="1"><data encoding="csv">0</data></layer>
+ <objectgroup id="3" name="Spawns"/>
+ <group id="4" name="Foreground">
+  <objectgroup id="5" name="Triggers"/>
+  <layer id="6" name="Roofs" width="1" height="1"><data encoding="csv">0</data></layer>
+ </group>
+ <layer id="7" name="Overlay" width="1" height="1"><data encoding="csv">0</data></layer>
+</map>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmx.AddLayer(Layer{Name: "Added"})
+
+	expected := []string{"Sky", "Ground", "Spawns", "Foreground", "Overlay", "Added"}
+	if names := layerNames(tmx.AllLayers()); !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+	if names := layerNames(tmx.Groups[0].AllLayers()); !reflect.DeepEqual(names, []string{"Triggers", "Roofs"}) {
+		t.Errorf("unexpected group layers: %v", names)
+	}
+
+	var buf strings.Builder
+	if err := tmx.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := Decode(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := layerNames(decoded.AllLayers()); !reflect.DeepEqual(names, expected) {
+		t.Errorf("encoding should preserve the document order, got %v", names)
+	}
+
+	tmj, err := LoadJSON("assets/json/objects.tmj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, layer := range tmj.AllLayers() {
+		if kind := reflect.TypeOf(layer).Elem().Name(); kind != []string{"Layer", "ObjectGroup", "Group"}[i] {
+			t.Errorf("layer %d: unexpected %s", i, kind)
+		}
+	}
+}
+
+func TestLookupByName(t *testing.T) {
+	tmx, err := Load("assets/embedded/group.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := tmx.Groups[0].Layers[0].Name
+	if layer, ok := tmx.Layer(name); !ok || layer != &tmx.Groups[0].Layers[0] {
+		t.Errorf("layer %q should be found in its group", name)
+	}
+	if _, ok := tmx.Layer("missing"); ok {
+		t.Errorf("missing layer should not be found")
+	}
+	if _, ok := tmx.ObjectGroup("missing"); ok {
+		t.Errorf("missing object group should not be found")
+	}
+	if tileSet, ok := tmx.TileSet(tmx.TileSets[0].Name); !ok || tileSet != tmx.TileSets[0] {
+		t.Errorf("tileset %q should be found", tmx.TileSets[0].Name)
+	}
+
+	objects, err := Load("assets/embedded/objects.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if og, ok := objects.ObjectGroup(objects.ObjectGroups[0].Name); !ok || og != &objects.ObjectGroups[0] {
+		t.Errorf("object group %q should be found", objects.ObjectGroups[0].Name)
+	}
+}
+
+func TestCompose(t *testing.T) {
+	tmx, err := Decode(strings.NewReader(`<map width="1" height="1" tilewidth="8" tileheight="8">
+ <group id="1" name="Outer" class="scene" offsetx="4" offsety="2" opacity="0.5" tintcolor="#ff8080">
+  <properties><property name="depth" type="int" value="3"/></properties>
+  <group id="2" name="Inner" offsetx="1" opacity="0.5" visible="0">
+   <layer id="3" name="Ground" width="1" height="1" offsety="-1" opacity="0.8"><data encoding="csv">0</data></layer>
+  </group>
+  <objectgroup id="4" name="Spawns" tintcolor="#80ffffff"/>
+ </group>
+ <imagelayer id="5" name="Sky" offsetx="3"/>
+</map>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	outer := &tmx.Groups[0]
+	if value, ok := outer.Property("depth"); outer.Class != "scene" || !ok || value != "3" {
+		t.Errorf("unexpected group class and properties: %q, %v", outer.Class, outer.Properties)
+	}
+
+	tests := []struct {
+		layer    LayerLike
+		expected Composition
+	}{
+		{&outer.Groups[0].Layers[0], Composition{5, 1, 0.2, false, color.RGBA{0xff, 0x80, 0x80, 0xff}}},
+		{&outer.ObjectGroups[0], Composition{4, 2, 0.5, true, color.RGBA{0xff, 0x80, 0x80, 0x80}}},
+		{&tmx.ImageLayers[0], Composition{3, 0, 1, true, color.RGBA{0xff, 0xff, 0xff, 0xff}}},
+	}
+	for _, test := range tests {
+		c, err := tmx.Compose(test.layer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c != test.expected {
+			t.Errorf("expected %+v, got %+v", test.expected, c)
+		}
+	}
+	if _, err := tmx.Compose(&Layer{}); err == nil {
+		t.Errorf("expected an error for a layer outside of the map")
+	}
+}
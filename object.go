@@ -0,0 +1,183 @@
+package tmxmap
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Point is a polygon or polyline vertex, relative to the object position.
+type Point struct {
+	X float64
+	Y float64
+}
+
+// Rect is an axis-aligned rectangle, Min being its top-left corner and Max its bottom-right corner.
+type Rect struct {
+	Min Point
+	Max Point
+}
+
+// Vertices parses the points of the polygon.
+func (p *Polygon) Vertices() ([]Point, error) {
+	return parsePoints(p.Points)
+}
+
+// Vertices parses the points of the polyline.
+func (p *PolyLine) Vertices() ([]Point, error) {
+	return parsePoints(p.Points)
+}
+
+// parsePoints parses space separated x,y pairs.
+func parsePoints(s string) ([]Point, error) {
+	fields := strings.Fields(s)
+	points := make([]Point, 0, len(fields))
+	for _, field := range fields {
+		coordinates := strings.Split(field, ",")
+		if len(coordinates) != 2 {
+			return nil, fmt.Errorf("invalid point: %s", field)
+		}
+		x, err := strconv.ParseFloat(coordinates[0], 64)
+		if err != nil {
+			return nil, err
+		}
+		y, err := strconv.ParseFloat(coordinates[1], 64)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, Point{X: x, Y: y})
+	}
+	return points, nil
+}
+
+// SortedObjects returns the objects of the group in draw order. Objects are sorted by Y when the draw order is
+// "topdown", and kept in file order when it is "index".
+func (og *ObjectGroup) SortedObjects() []*Object {
+	objects := make([]*Object, len(og.Objects))
+	for i := range og.Objects {
+		objects[i] = &og.Objects[i]
+	}
+	if og.DrawOrder != "index" {
+		sort.SliceStable(objects, func(i, j int) bool {
+			return objects[i].Y < objects[j].Y
+		})
+	}
+	return objects
+}
+
+// AllObjects returns the objects of all the object groups of the map, the top-level groups first, then the ones
+// nested in groups. The objects can be modified in place.
+func (m *Map) AllObjects() []*Object {
+	var objects []*Object
+	m.walkObjectGroups(m.ObjectGroups, m.Groups, func(og *ObjectGroup) error {
+		for i := range og.Objects {
+			objects = append(objects, &og.Objects[i])
+		}
+		return nil
+	})
+	return objects
+}
+
+// ObjectsByType returns the objects of the map whose type or class is t, in the order of AllObjects.
+func (m *Map) ObjectsByType(t string) []*Object {
+	var objects []*Object
+	for _, o := range m.AllObjects() {
+		if o.Type == t || o.Class == t {
+			objects = append(objects, o)
+		}
+	}
+	return objects
+}
+
+// Bounds returns the axis-aligned bounding box of the object in pixels, rotation included. Objects rotate clockwise
+// around their position, which is the top-left corner of shapes. Tile objects are anchored following the object
+// alignment of their tileset, bottom-left when unspecified as on orthogonal maps.
+func (o *Object) Bounds() (Rect, error) {
+	sin, cos := math.Sincos(o.Rotation * math.Pi / 180)
+	rotate := func(p Point) Point {
+		return Point{X: o.X + p.X*cos - p.Y*sin, Y: o.Y + p.X*sin + p.Y*cos}
+	}
+
+	var points []Point
+	switch {
+	case o.Point != nil:
+		return Rect{Min: Point{o.X, o.Y}, Max: Point{o.X, o.Y}}, nil
+	case o.Ellipse != nil:
+		// The extents of a rotated ellipse are narrower than the ones of its rotated bounding box.
+		rx, ry := o.Width/2, o.Height/2
+		center := rotate(Point{X: rx, Y: ry})
+		ex := math.Sqrt(rx*rx*cos*cos + ry*ry*sin*sin)
+		ey := math.Sqrt(rx*rx*sin*sin + ry*ry*cos*cos)
+		return Rect{Min: Point{center.X - ex, center.Y - ey}, Max: Point{center.X + ex, center.Y + ey}}, nil
+	case len(o.Polygons) > 0 || len(o.PolyLines) > 0:
+		for _, polygon := range o.Polygons {
+			vertices, err := polygon.Vertices()
+			if err != nil {
+				return Rect{}, err
+			}
+			points = append(points, vertices...)
+		}
+		for _, polyLine := range o.PolyLines {
+			vertices, err := polyLine.Vertices()
+			if err != nil {
+				return Rect{}, err
+			}
+			points = append(points, vertices...)
+		}
+	case o.GID != 0:
+		alignment := "bottomleft"
+		if o.Tile != nil && o.Tile.TileSet != nil {
+			alignment = o.Tile.TileSet.objectAlignment("orthogonal")
+		}
+		x, y := alignmentOffset(alignment, o.Width, o.Height)
+		points = []Point{{x, y}, {x + o.Width, y}, {x + o.Width, y + o.Height}, {x, y + o.Height}}
+	default:
+		points = []Point{{0, 0}, {o.Width, 0}, {o.Width, o.Height}, {0, o.Height}}
+	}
+
+	if len(points) == 0 {
+		return Rect{Min: Point{o.X, o.Y}, Max: Point{o.X, o.Y}}, nil
+	}
+	bounds := Rect{Min: rotate(points[0]), Max: rotate(points[0])}
+	for _, p := range points[1:] {
+		p = rotate(p)
+		bounds.Min.X, bounds.Min.Y = min(bounds.Min.X, p.X), min(bounds.Min.Y, p.Y)
+		bounds.Max.X, bounds.Max.Y = max(bounds.Max.X, p.X), max(bounds.Max.Y, p.Y)
+	}
+	return bounds, nil
+}
+
+// ObjectAlignment returns the alignment of the tile objects using the tileset. Unspecified alignments default to
+// bottomleft, or bottom on isometric maps.
+func (m *Map) ObjectAlignment(ts *TileSet) string {
+	return ts.objectAlignment(m.Orientation)
+}
+
+func (ts *TileSet) objectAlignment(orientation string) string {
+	if ts.ObjectAlignment != "" && ts.ObjectAlignment != "unspecified" {
+		return ts.ObjectAlignment
+	}
+	if orientation == "isometric" {
+		return "bottom"
+	}
+	return "bottomleft"
+}
+
+// alignmentOffset returns the position of the top-left corner of a tile object relative to its anchor.
+func alignmentOffset(alignment string, width, height float64) (x, y float64) {
+	switch alignment {
+	case "top", "center", "bottom":
+		x = -width / 2
+	case "topright", "right", "bottomright":
+		x = -width
+	}
+	switch alignment {
+	case "left", "center", "right":
+		y = -height / 2
+	case "bottomleft", "bottom", "bottomright":
+		y = -height
+	}
+	return x, y
+}
@@ -0,0 +1,284 @@
+package tmxmap
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestVertices(t *testing.T) {
+	polygon := Polygon{Points: "0,0 32.5,0 32.5,-16.25 -8,4"}
+	vertices, err := polygon.Vertices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Point{{0, 0}, {32.5, 0}, {32.5, -16.25}, {-8, 4}}
+	if !reflect.DeepEqual(vertices, expected) {
+		t.Errorf("expected %v, got %v", expected, vertices)
+	}
+
+	tmx, err := Load("assets/embedded/objects.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vertices, err = tmx.ObjectGroups[0].Objects[4].PolyLines[0].Vertices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vertices) != 3 || vertices[2] != (Point{32, 32}) {
+		t.Errorf("unexpected polyline vertices: %v", vertices)
+	}
+
+	for _, invalid := range []string{"0,0 1", "a,b", "1,2,3"} {
+		if _, err := (&Polygon{Points: invalid}).Vertices(); err == nil {
+			t.Errorf("%q: expected an error", invalid)
+		}
+	}
+}
+
+func TestSortedObjects(t *testing.T) {
+	tmx, err := Load("assets/embedded/objects.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	og := &tmx.ObjectGroups[0]
+	if og.DrawOrder != "topdown" {
+		t.Errorf("expected the topdown default, got %q", og.DrawOrder)
+	}
+	if og.OffsetX != 4 || og.OffsetY != -2 || og.ParallaxX != 0.5 || og.ParallaxY != 1 {
+		t.Errorf("unexpected offset and parallax: %d,%d %f,%f", og.OffsetX, og.OffsetY, og.ParallaxX, og.ParallaxY)
+	}
+
+	var names []string
+	var ids []int
+	for _, object := range og.SortedObjects() {
+		names = append(names, object.Name)
+		ids = append(ids, object.ID)
+	}
+	expected := []string{"wall", "path", "pond", "tree", "spawn", "rock", "sign"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+	if expectedIDs := []int{1, 5, 2, 6, 3, 4, 7}; !reflect.DeepEqual(ids, expectedIDs) {
+		t.Errorf("expected IDs %v, got %v", expectedIDs, ids)
+	}
+
+	og.DrawOrder = "index"
+	if objects := og.SortedObjects(); objects[1] != &og.Objects[1] {
+		t.Errorf("index draw order should keep the file order")
+	}
+}
+
+func TestObjectTile(t *testing.T) {
+	tmx, err := Load("assets/embedded/objects.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, object := range tmx.ObjectGroups[0].Objects {
+		if object.Kind() != TileObject {
+			if object.Tile != nil {
+				t.Errorf("object %q should not have a tile", object.Name)
+			}
+			continue
+		}
+		if object.Tile == nil || object.Tile.ID != 11 || object.Tile.TileSet != tmx.TileSets[0] {
+			t.Errorf("unexpected tile for object %q: %+v", object.Name, object.Tile)
+		}
+	}
+
+	tmx, err = Load("assets/templates/track1_bg.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tile := tmx.ObjectGroups[0].Objects[0].Tile; tile == nil || tile.TileSet != tmx.TileSets[1] || tile.ID != 4 {
+		t.Errorf("unexpected tile for the template object: %+v", tile)
+	}
+}
+
+func TestSubPixelObjects(t *testing.T) {
+	tmx, err := Decode(strings.NewReader(`<map width="1" height="1" tilewidth="8" tileheight="8">
+ <objectgroup id="1"><object id="1" x="12.75" y="-3.5" width="10.25" height="0.5"/></objectgroup>
+</map>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	object := tmx.ObjectGroups[0].Objects[0]
+	if object.X != 12.75 || object.Y != -3.5 || object.Width != 10.25 || object.Height != 0.5 {
+		t.Errorf("unexpected object: %+v", object)
+	}
+
+	var buf strings.Builder
+	if err := tmx.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `x="12.75" y="-3.5" width="10.25" height="0.5"`) {
+		t.Errorf("encoded object should keep its coordinates:\n%s", buf.String())
+	}
+
+	tmj, err := DecodeJSON(strings.NewReader(`{"width":1,"height":1,"tilewidth":8,"tileheight":8,"layers":[
+ {"type":"objectgroup","objects":[{"id":1,"x":12.75,"y":-3.5,"width":10.25,"height":0.5}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if object := tmj.ObjectGroups[0].Objects[0]; object.X != 12.75 || object.Width != 10.25 {
+		t.Errorf("unexpected JSON object: %+v", object)
+	}
+}
+
+func TestObjectBounds(t *testing.T) {
+	tests := []struct {
+		name     string
+		object   Object
+		expected Rect
+	}{
+		{"rectangle", Object{X: 10, Y: 20, Width: 32, Height: 16}, Rect{Point{10, 20}, Point{42, 36}}},
+		{"rotated rectangle", Object{X: 10, Y: 20, Width: 32, Height: 16, Rotation: 90}, Rect{Point{-6, 20}, Point{10, 52}}},
+		{"rotated ellipse", Object{X: 0, Y: 0, Width: 20, Height: 10, Rotation: 90, Ellipse: &struct{}{}}, Rect{Point{-10, 0}, Point{0, 20}}},
+		{"polygon", Object{X: 5, Y: 5, Polygons: []Polygon{{Points: "0,0 10,-4 -2,6"}}}, Rect{Point{3, 1}, Point{15, 11}}},
+		{"rotated polyline", Object{X: 0, Y: 0, Rotation: 180, PolyLines: []PolyLine{{Points: "0,0 8,4"}}}, Rect{Point{-8, -4}, Point{0, 0}}},
+		{"tile", Object{X: 16, Y: 32, Width: 16, Height: 16, GID: 1}, Rect{Point{16, 16}, Point{32, 32}}},
+		{"centered tile", Object{X: 16, Y: 32, Width: 16, Height: 8, GID: 1, Tile: &TileInfo{TileSet: &TileSet{ObjectAlignment: "center"}}}, Rect{Point{8, 28}, Point{24, 36}}},
+		{"top-right tile", Object{X: 16, Y: 32, Width: 16, Height: 8, GID: 1, Tile: &TileInfo{TileSet: &TileSet{ObjectAlignment: "topright"}}}, Rect{Point{0, 32}, Point{16, 40}}},
+		{"point", Object{X: 3, Y: 4, Point: &struct{}{}}, Rect{Point{3, 4}, Point{3, 4}}},
+	}
+	near := func(a, b Point) bool { return math.Abs(a.X-b.X) < 1e-9 && math.Abs(a.Y-b.Y) < 1e-9 }
+	for _, test := range tests {
+		bounds, err := test.object.Bounds()
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !near(bounds.Min, test.expected.Min) || !near(bounds.Max, test.expected.Max) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, bounds)
+		}
+	}
+
+	invalid := Object{Polygons: []Polygon{{Points: "0,0 1"}}}
+	if _, err := invalid.Bounds(); err == nil {
+		t.Errorf("expected an error for invalid polygon points")
+	}
+}
+
+func TestObjectAlignment(t *testing.T) {
+	tmx, decoded := roundTrip(t, "assets/collection/objects.tmx", EncodeOptions{})
+	if tmx.TileSets[0].ObjectAlignment != "unspecified" || decoded.TileSets[0].ObjectAlignment != "unspecified" {
+		t.Errorf("unexpected object alignment: %s", tmx.TileSets[0].ObjectAlignment)
+	}
+	tmj, err := DecodeJSON(strings.NewReader(`{"tilesets":[{"firstgid":1,"name":"tiles"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmj.TileSets[0].ObjectAlignment != "unspecified" {
+		t.Errorf("unexpected JSON object alignment: %s", tmj.TileSets[0].ObjectAlignment)
+	}
+	if alignment := decoded.ObjectAlignment(decoded.TileSets[0]); alignment != "bottomleft" {
+		t.Errorf("expected the orthogonal default, got %s", alignment)
+	}
+	if alignment := (&Map{Orientation: "isometric"}).ObjectAlignment(&TileSet{ObjectAlignment: "unspecified"}); alignment != "bottom" {
+		t.Errorf("expected the isometric default, got %s", alignment)
+	}
+
+	m, err := Decode(strings.NewReader(`<map width="1" height="1"><tileset firstgid="1" name="tiles" objectalignment="top"/></map>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alignment := m.ObjectAlignment(m.TileSets[0]); alignment != "top" {
+		t.Errorf("unexpected object alignment: %s", alignment)
+	}
+	var buf strings.Builder
+	if err := m.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `objectalignment="top"`) {
+		t.Errorf("encoded tileset should keep its object alignment:\n%s", buf.String())
+	}
+}
+
+func TestObjectClass(t *testing.T) {
+	tmx, err := Decode(strings.NewReader(`<map width="1" height="1"><objectgroup id="1">
+ <object id="1" type="spawn"/>
+ <object id="2" class="spawn"/>
+ <object id="3" type="spawn" class="enemy"/>
+</objectgroup></map>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, object := range tmx.ObjectGroups[0].Objects {
+		if object.Type != "spawn" {
+			t.Errorf("object %d: expected type spawn, got %q", i, object.Type)
+		}
+	}
+
+	var buf strings.Builder
+	if err := tmx.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{`<object id="1" type="spawn"`, `<object id="2" class="spawn"`, `<object id="3" type="spawn" class="enemy"`} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("encoded map should contain %s:\n%s", s, buf.String())
+		}
+	}
+
+	tmj, err := DecodeJSON(strings.NewReader(`{"layers":[{"type":"objectgroup","objects":[{"id":1,"class":"spawn"}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if object := tmj.ObjectGroups[0].Objects[0]; object.Type != "spawn" {
+		t.Errorf("expected type spawn, got %q", object.Type)
+	}
+
+	fsys := fstest.MapFS{
+		"chest.tx": &fstest.MapFile{Data: []byte(`<template><object name="chest" type="container"/></template>`)},
+		"map.tmx": &fstest.MapFile{Data: []byte(`<map width="1" height="1"><objectgroup id="1">
+ <object id="1" template="chest.tx"/>
+ <object id="2" template="chest.tx" class="mimic"/>
+</objectgroup></map>`)},
+	}
+	templated, err := LoadFS(fsys, "map.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chest, mimic := templated.ObjectGroups[0].Objects[0], templated.ObjectGroups[0].Objects[1]; chest.Type != "container" || mimic.Type != "mimic" {
+		t.Errorf("unexpected templated types: %q, %q", chest.Type, mimic.Type)
+	}
+}
+
+func TestAllObjects(t *testing.T) {
+	tmx, err := Decode(strings.NewReader(`<map width="1" height="1">
+ <objectgroup id="1" name="Spawns">
+  <object id="1" type="spawn"/>
+  <object id="2" type="trigger"/>
+ </objectgroup>
+ <group id="2" name="Level">
+  <objectgroup id="3" name="Enemies">
+   <object id="3" class="spawn"/>
+   <object id="4" type="spawn" class="boss"/>
+  </objectgroup>
+ </group>
+</map>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := func(objects []*Object) []int {
+		var ids []int
+		for _, o := range objects {
+			ids = append(ids, o.ID)
+		}
+		return ids
+	}
+	if all := tmx.AllObjects(); !reflect.DeepEqual(ids(all), []int{1, 2, 3, 4}) {
+		t.Errorf("unexpected objects: %v", ids(all))
+	}
+	if spawns := tmx.ObjectsByType("spawn"); !reflect.DeepEqual(ids(spawns), []int{1, 3, 4}) {
+		t.Errorf("unexpected spawns: %v", ids(spawns))
+	}
+	if bosses := tmx.ObjectsByType("boss"); !reflect.DeepEqual(ids(bosses), []int{4}) {
+		t.Errorf("unexpected bosses: %v", ids(bosses))
+	}
+
+	tmx.AllObjects()[2].Name = "goblin"
+	if tmx.Groups[0].ObjectGroups[0].Objects[0].Name != "goblin" {
+		t.Errorf("objects should be modified in place")
+	}
+}
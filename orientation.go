@@ -0,0 +1,338 @@
+package tmxmap
+
+import (
+	"image"
+	"math"
+)
+
+// StaggerAxis is the axis staggered and hexagonal maps are staggered along.
+type StaggerAxis string
+
+const (
+	StaggerAxisX StaggerAxis = "x"
+	StaggerAxisY StaggerAxis = "y"
+)
+
+// StaggerIndex tells whether the odd or the even rows or columns of staggered and hexagonal maps are shifted.
+type StaggerIndex string
+
+const (
+	StaggerIndexEven StaggerIndex = "even"
+	StaggerIndexOdd  StaggerIndex = "odd"
+)
+
+// staggerParams holds the layout of staggered and hexagonal maps, mirroring Tiled's hexagonal renderer.
+type staggerParams struct {
+	tileWidth   int
+	tileHeight  int
+	sideLengthX int
+	sideLengthY int
+	sideOffsetX int
+	sideOffsetY int
+	columnWidth int
+	rowHeight   int
+	staggerX    bool
+	staggerEven bool
+}
+
+func (m *Map) staggerParams() staggerParams {
+	p := staggerParams{
+		tileWidth:   m.TileWidth &^ 1,
+		tileHeight:  m.TileHeight &^ 1,
+		staggerX:    m.StaggerAxis == StaggerAxisX,
+		staggerEven: m.StaggerIndex == StaggerIndexEven,
+	}
+	if m.Orientation == "hexagonal" {
+		if p.staggerX {
+			p.sideLengthX = m.HexSideLength
+		} else {
+			p.sideLengthY = m.HexSideLength
+		}
+	}
+	p.sideOffsetX = (p.tileWidth - p.sideLengthX) / 2
+	p.sideOffsetY = (p.tileHeight - p.sideLengthY) / 2
+	p.columnWidth = p.sideOffsetX + p.sideLengthX
+	p.rowHeight = p.sideOffsetY + p.sideLengthY
+	return p
+}
+
+func (p staggerParams) doStaggerX(x int) bool {
+	return p.staggerX && (x&1 == 1) != p.staggerEven
+}
+
+func (p staggerParams) doStaggerY(y int) bool {
+	return !p.staggerX && (y&1 == 1) != p.staggerEven
+}
+
+// TileToPixel returns the top-left corner of the bounding box of the tile at the given column and row, in pixels.
+// Orthogonal, isometric, staggered and hexagonal orientations are supported.
+func (m *Map) TileToPixel(x, y int) (px, py int) {
+	switch m.Orientation {
+	case "isometric":
+		return (x-y)*m.TileWidth/2 + (m.Height-1)*m.TileWidth/2, (x + y) * m.TileHeight / 2
+	case "staggered", "hexagonal":
+		p := m.staggerParams()
+		if p.staggerX {
+			py = y * (p.tileHeight + p.sideLengthY)
+			if p.doStaggerX(x) {
+				py += p.rowHeight
+			}
+			return x * p.columnWidth, py
+		}
+		px = x * (p.tileWidth + p.sideLengthX)
+		if p.doStaggerY(y) {
+			px += p.columnWidth
+		}
+		return px, y * p.rowHeight
+	}
+	return x * m.TileWidth, y * m.TileHeight
+}
+
+// HexTileBounds returns the bounding box of the hexagon of the tile at the given column and row, in pixels. Flat-top
+// hexagons are staggered along the x axis and pointy-top ones along the y axis, their sides being HexSideLength
+// long. Staggered maps are laid out the same way, with diamonds instead of hexagons.
+func (m *Map) HexTileBounds(x, y int) image.Rectangle {
+	px, py := m.TileToPixel(x, y)
+	p := m.staggerParams()
+	return image.Rect(px, py, px+p.tileWidth, py+p.tileHeight)
+}
+
+// PixelSize returns the size of the whole map in pixels, following its orientation. Infinite maps report the size
+// of the area given by their width and height.
+func (m *Map) PixelSize() (width, height int) {
+	switch m.Orientation {
+	case "isometric":
+		return (m.Width + m.Height) * m.TileWidth / 2, (m.Width + m.Height) * m.TileHeight / 2
+	case "staggered", "hexagonal":
+		p := m.staggerParams()
+		if p.staggerX {
+			width, height = m.Width*p.columnWidth+p.sideOffsetX, m.Height*(p.tileHeight+p.sideLengthY)
+			if m.Width > 1 {
+				height += p.rowHeight
+			}
+			return width, height
+		}
+		width, height = m.Width*(p.tileWidth+p.sideLengthX), m.Height*p.rowHeight+p.sideOffsetY
+		if m.Height > 1 {
+			width += p.columnWidth
+		}
+		return width, height
+	}
+	return m.Width * m.TileWidth, m.Height * m.TileHeight
+}
+
+// PixelToTile returns the column and row of the tile containing the given pixel.
+// Orthogonal, isometric, staggered and hexagonal orientations are supported.
+func (m *Map) PixelToTile(px, py int) (x, y int) {
+	switch m.Orientation {
+	case "isometric":
+		tx := float64(px-m.Height*m.TileWidth/2) / float64(m.TileWidth)
+		ty := float64(py) / float64(m.TileHeight)
+		return int(math.Floor(ty + tx)), int(math.Floor(ty - tx))
+	case "staggered":
+		return m.staggeredPixelToTile(float64(px), float64(py))
+	case "hexagonal":
+		return m.hexagonalPixelToTile(float64(px), float64(py))
+	}
+	return int(math.Floor(float64(px) / float64(m.TileWidth))), int(math.Floor(float64(py) / float64(m.TileHeight)))
+}
+
+func (m *Map) staggeredPixelToTile(px, py float64) (int, int) {
+	p := m.staggerParams()
+	if p.staggerX {
+		if p.staggerEven {
+			px -= float64(p.sideOffsetX)
+		}
+	} else if p.staggerEven {
+		py -= float64(p.sideOffsetY)
+	}
+
+	// Start with the coordinates of a grid-aligned tile
+	x := int(math.Floor(px / float64(p.tileWidth)))
+	y := int(math.Floor(py / float64(p.tileHeight)))
+	relX := px - float64(x*p.tileWidth)
+	relY := py - float64(y*p.tileHeight)
+
+	// Adjust the reference point to the correct tile coordinates
+	if p.staggerX {
+		x *= 2
+		if p.staggerEven {
+			x++
+		}
+	} else {
+		y *= 2
+		if p.staggerEven {
+			y++
+		}
+	}
+
+	// Check whether the pixel is in any of the corners, which belong to neighboring tiles
+	yPos := relX * float64(p.tileHeight) / float64(p.tileWidth)
+	sideOffsetY := float64(p.sideOffsetY)
+	switch {
+	case sideOffsetY-yPos > relY:
+		return m.staggeredNeighbor(x, y, -1, -1)
+	case -sideOffsetY+yPos > relY:
+		return m.staggeredNeighbor(x, y, 1, -1)
+	case sideOffsetY+yPos < relY:
+		return m.staggeredNeighbor(x, y, -1, 1)
+	case sideOffsetY*3-yPos < relY:
+		return m.staggeredNeighbor(x, y, 1, 1)
+	}
+	return x, y
+}
+
+// staggeredNeighbor returns the diagonal neighbor of a staggered tile in the given direction.
+func (m *Map) staggeredNeighbor(x, y, dx, dy int) (int, int) {
+	p := m.staggerParams()
+	if p.staggerX {
+		// Columns are shifted down when staggered.
+		if p.doStaggerX(x) {
+			if dy < 0 {
+				return x + dx, y
+			}
+			return x + dx, y + 1
+		}
+		if dy < 0 {
+			return x + dx, y - 1
+		}
+		return x + dx, y
+	}
+	// Rows are shifted right when staggered.
+	if p.doStaggerY(y) {
+		if dx < 0 {
+			return x, y + dy
+		}
+		return x + 1, y + dy
+	}
+	if dx < 0 {
+		return x - 1, y + dy
+	}
+	return x, y + dy
+}
+
+func (m *Map) hexagonalPixelToTile(px, py float64) (int, int) {
+	p := m.staggerParams()
+	if p.staggerX {
+		if p.staggerEven {
+			px -= float64(p.tileWidth)
+		} else {
+			px -= float64(p.sideOffsetX)
+		}
+	} else {
+		if p.staggerEven {
+			py -= float64(p.tileHeight)
+		} else {
+			py -= float64(p.sideOffsetY)
+		}
+	}
+
+	// Start with the coordinates of a grid-aligned tile
+	x := int(math.Floor(px / float64(p.columnWidth*2)))
+	y := int(math.Floor(py / float64(p.rowHeight*2)))
+
+	// Relative position on the base square of the grid-aligned tile
+	relX := px - float64(x*p.columnWidth*2)
+	relY := py - float64(y*p.rowHeight*2)
+
+	// Adjust the reference point to the correct tile coordinates
+	var centers [4][2]float64
+	var offsets [4][2]int
+	if p.staggerX {
+		x *= 2
+		if p.staggerEven {
+			x++
+		}
+		left := float64(p.sideLengthX / 2)
+		centerX := left + float64(p.columnWidth)
+		centerY := float64(p.tileHeight / 2)
+		centers = [4][2]float64{
+			{left, centerY},
+			{centerX, centerY - float64(p.rowHeight)},
+			{centerX, centerY + float64(p.rowHeight)},
+			{centerX + float64(p.columnWidth), centerY},
+		}
+		offsets = [4][2]int{{0, 0}, {1, -1}, {1, 0}, {2, 0}}
+	} else {
+		y *= 2
+		if p.staggerEven {
+			y++
+		}
+		top := float64(p.sideLengthY / 2)
+		centerX := float64(p.tileWidth / 2)
+		centerY := top + float64(p.rowHeight)
+		centers = [4][2]float64{
+			{centerX, top},
+			{centerX - float64(p.columnWidth), centerY},
+			{centerX + float64(p.columnWidth), centerY},
+			{centerX, centerY + float64(p.rowHeight)},
+		}
+		offsets = [4][2]int{{0, 0}, {-1, 1}, {0, 1}, {0, 2}}
+	}
+
+	// Determine the nearest hexagon tile by the distance to the center
+	nearest, minDist := 0, math.MaxFloat64
+	for i, center := range centers {
+		dx, dy := center[0]-relX, center[1]-relY
+		if dist := dx*dx + dy*dy; dist < minDist {
+			nearest, minDist = i, dist
+		}
+	}
+	return x + offsets[nearest][0], y + offsets[nearest][1]
+}
+
+// Neighbors returns the coordinates of the tiles adjacent to the given tile, clockwise from the top. Orthogonal and
+// isometric maps have 8 neighbors, in tile coordinates, staggered maps have 8 and hexagonal maps have 6, following
+// the stagger axis and index. Neighbors outside of the map are left out, except for infinite maps.
+func (m *Map) Neighbors(x, y int) []image.Point {
+	var neighbors []image.Point
+	add := func(nx, ny int) {
+		if m.Infinite || nx >= 0 && ny >= 0 && nx < m.Width && ny < m.Height {
+			neighbors = append(neighbors, image.Pt(nx, ny))
+		}
+	}
+	diagonal := func(dx, dy int) {
+		add(m.staggeredNeighbor(x, y, dx, dy))
+	}
+
+	staggerX := m.StaggerAxis == StaggerAxisX
+	switch {
+	case m.Orientation == "hexagonal" && staggerX:
+		add(x, y-1)
+		diagonal(1, -1)
+		diagonal(1, 1)
+		add(x, y+1)
+		diagonal(-1, 1)
+		diagonal(-1, -1)
+	case m.Orientation == "hexagonal":
+		diagonal(1, -1)
+		add(x+1, y)
+		diagonal(1, 1)
+		diagonal(-1, 1)
+		add(x-1, y)
+		diagonal(-1, -1)
+	case m.Orientation == "staggered" && staggerX:
+		add(x, y-1)
+		diagonal(1, -1)
+		add(x+2, y)
+		diagonal(1, 1)
+		add(x, y+1)
+		diagonal(-1, 1)
+		add(x-2, y)
+		diagonal(-1, -1)
+	case m.Orientation == "staggered":
+		add(x, y-2)
+		diagonal(1, -1)
+		add(x+1, y)
+		diagonal(1, 1)
+		add(x, y+2)
+		diagonal(-1, 1)
+		add(x-1, y)
+		diagonal(-1, -1)
+	default:
+		for _, d := range [8][2]int{{0, -1}, {1, -1}, {1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}} {
+			add(x+d[0], y+d[1])
+		}
+	}
+	return neighbors
+}
@@ -0,0 +1,206 @@
+package tmxmap
+
+import (
+	"image"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func orientationMaps() map[string]*Map {
+	return map[string]*Map{
+		"orthogonal":       {Orientation: "orthogonal", Width: 10, Height: 10, TileWidth: 16, TileHeight: 16},
+		"isometric":        {Orientation: "isometric", Width: 10, Height: 10, TileWidth: 64, TileHeight: 32},
+		"staggered y odd":  {Orientation: "staggered", Width: 10, Height: 10, TileWidth: 64, TileHeight: 32, StaggerAxis: "y", StaggerIndex: "odd"},
+		"staggered x even": {Orientation: "staggered", Width: 10, Height: 10, TileWidth: 64, TileHeight: 32, StaggerAxis: "x", StaggerIndex: "even"},
+		"hexagonal y odd":  {Orientation: "hexagonal", Width: 10, Height: 10, TileWidth: 32, TileHeight: 28, HexSideLength: 14, StaggerAxis: "y", StaggerIndex: "odd"},
+		"hexagonal x even": {Orientation: "hexagonal", Width: 10, Height: 10, TileWidth: 28, TileHeight: 32, HexSideLength: 14, StaggerAxis: "x", StaggerIndex: "even"},
+		"hexagonal y even": {Orientation: "hexagonal", Width: 10, Height: 10, TileWidth: 32, TileHeight: 28, HexSideLength: 14, StaggerAxis: "y", StaggerIndex: "even"},
+		"hexagonal x odd":  {Orientation: "hexagonal", Width: 10, Height: 10, TileWidth: 28, TileHeight: 32, HexSideLength: 14, StaggerAxis: "x", StaggerIndex: "odd"},
+		"staggered x odd":  {Orientation: "staggered", Width: 10, Height: 10, TileWidth: 64, TileHeight: 32, StaggerAxis: "x", StaggerIndex: "odd"},
+		"staggered y even": {Orientation: "staggered", Width: 10, Height: 10, TileWidth: 64, TileHeight: 32, StaggerAxis: "y", StaggerIndex: "even"},
+	}
+}
+
+func TestTileToPixel(t *testing.T) {
+	maps := orientationMaps()
+	tests := []struct {
+		name   string
+		x, y   int
+		px, py int
+	}{
+		{"orthogonal", 3, 2, 48, 32},
+		{"isometric", 0, 0, 288, 0},
+		{"isometric", 1, 0, 320, 16},
+		{"isometric", 0, 1, 256, 16},
+		{"staggered y odd", 0, 0, 0, 0},
+		{"staggered y odd", 0, 1, 32, 16},
+		{"staggered y odd", 1, 2, 64, 32},
+		{"staggered x even", 0, 0, 0, 16},
+		{"staggered x even", 1, 0, 32, 0},
+		{"hexagonal y odd", 0, 1, 16, 21},
+		{"hexagonal y odd", 1, 0, 32, 0},
+		{"hexagonal x even", 0, 0, 0, 16},
+		{"hexagonal x even", 1, 0, 21, 0},
+	}
+	for _, test := range tests {
+		px, py := maps[test.name].TileToPixel(test.x, test.y)
+		if px != test.px || py != test.py {
+			t.Errorf("%s: tile %d,%d: expected %d,%d, got %d,%d", test.name, test.x, test.y, test.px, test.py, px, py)
+		}
+	}
+}
+
+func TestPixelSize(t *testing.T) {
+	maps := orientationMaps()
+	tests := []struct {
+		name          string
+		width, height int
+	}{
+		{"orthogonal", 160, 160},
+		{"isometric", 640, 320},
+		{"staggered y odd", 672, 176},
+		{"hexagonal y odd", 336, 217},
+		{"hexagonal x even", 217, 336},
+	}
+	for _, test := range tests {
+		if width, height := maps[test.name].PixelSize(); width != test.width || height != test.height {
+			t.Errorf("%s: expected %dx%d, got %dx%d", test.name, test.width, test.height, width, height)
+		}
+	}
+
+	// Every tile fits within the map.
+	for name, m := range maps {
+		width, height := m.PixelSize()
+		for y := range m.Height {
+			for x := range m.Width {
+				px, py := m.TileToPixel(x, y)
+				if px < 0 || py < 0 || px+m.TileWidth > width || py+m.TileHeight > height {
+					t.Errorf("%s: tile %d,%d at %d,%d does not fit in %dx%d", name, x, y, px, py, width, height)
+				}
+			}
+		}
+	}
+}
+
+func TestPixelToTile(t *testing.T) {
+	for name, m := range orientationMaps() {
+		for y := 0; y < m.Height; y++ {
+			for x := 0; x < m.Width; x++ {
+				px, py := m.TileToPixel(x, y)
+				tx, ty := m.PixelToTile(px+m.TileWidth/2, py+m.TileHeight/2)
+				if tx != x || ty != y {
+					t.Errorf("%s: center of tile %d,%d maps to %d,%d", name, x, y, tx, ty)
+				}
+			}
+		}
+	}
+}
+
+func TestPixelToTileCorners(t *testing.T) {
+	maps := orientationMaps()
+	tests := []struct {
+		name   string
+		px, py int
+		x, y   int
+	}{
+		{"staggered y odd", 1, 1, -1, -1},
+		{"staggered y odd", 63, 1, 0, -1},
+		{"staggered y odd", 1, 31, -1, 1},
+		{"staggered y odd", 63, 31, 0, 1},
+		{"isometric", 321, 1, 0, 0},
+		{"isometric", 300, 1, -1, 0},
+	}
+	for _, test := range tests {
+		x, y := maps[test.name].PixelToTile(test.px, test.py)
+		if x != test.x || y != test.y {
+			t.Errorf("%s: pixel %d,%d: expected tile %d,%d, got %d,%d", test.name, test.px, test.py, test.x, test.y, x, y)
+		}
+	}
+}
+
+func TestNeighbors(t *testing.T) {
+	maps := orientationMaps()
+	tests := []struct {
+		name     string
+		x, y     int
+		expected []image.Point
+	}{
+		{"orthogonal", 0, 0, []image.Point{{1, 0}, {1, 1}, {0, 1}}},
+		{"orthogonal", 9, 9, []image.Point{{9, 8}, {8, 9}, {8, 8}}},
+		{"staggered y odd", 2, 2, []image.Point{{2, 0}, {2, 1}, {3, 2}, {2, 3}, {2, 4}, {1, 3}, {1, 2}, {1, 1}}},
+		{"hexagonal y odd", 2, 3, []image.Point{{3, 2}, {3, 3}, {3, 4}, {2, 4}, {1, 3}, {2, 2}}},
+		{"hexagonal x even", 2, 3, []image.Point{{2, 2}, {3, 3}, {3, 4}, {2, 4}, {1, 4}, {1, 3}}},
+	}
+	for _, test := range tests {
+		if neighbors := maps[test.name].Neighbors(test.x, test.y); !reflect.DeepEqual(neighbors, test.expected) {
+			t.Errorf("%s: tile %d,%d: expected %v, got %v", test.name, test.x, test.y, test.expected, neighbors)
+		}
+	}
+
+	// Adjacency is symmetric whatever the orientation.
+	for name, m := range maps {
+		for y := range m.Height {
+			for x := range m.Width {
+				for _, n := range m.Neighbors(x, y) {
+					if !slices.Contains(m.Neighbors(n.X, n.Y), image.Pt(x, y)) {
+						t.Errorf("%s: tile %d,%d is a neighbor of %v but not the other way around", name, n.X, n.Y, image.Pt(x, y))
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestHexTileBounds(t *testing.T) {
+	maps := orientationMaps()
+	tests := []struct {
+		name     string
+		x, y     int
+		expected image.Rectangle
+	}{
+		{"hexagonal y odd", 0, 0, image.Rect(0, 0, 32, 28)},
+		{"hexagonal y odd", 0, 1, image.Rect(16, 21, 48, 49)},
+		{"hexagonal x even", 0, 0, image.Rect(0, 16, 28, 48)},
+		{"hexagonal x even", 1, 0, image.Rect(21, 0, 49, 32)},
+	}
+	for _, test := range tests {
+		if bounds := maps[test.name].HexTileBounds(test.x, test.y); bounds != test.expected {
+			t.Errorf("%s: tile %d,%d: expected %v, got %v", test.name, test.x, test.y, test.expected, bounds)
+		}
+	}
+
+	for name, m := range maps {
+		if m.Orientation != "hexagonal" {
+			continue
+		}
+		for y := range m.Height {
+			for x := range m.Width {
+				bounds := m.HexTileBounds(x, y)
+				cx, cy := (bounds.Min.X+bounds.Max.X)/2, (bounds.Min.Y+bounds.Max.Y)/2
+				if tx, ty := m.PixelToTile(cx, cy); tx != x || ty != y {
+					t.Errorf("%s: center of tile %d,%d maps to %d,%d", name, x, y, tx, ty)
+				}
+			}
+		}
+	}
+}
+
+func TestStaggerAttributes(t *testing.T) {
+	tmx, err := Decode(strings.NewReader(`<map orientation="hexagonal" width="1" height="1" tilewidth="28" tileheight="32" hexsidelength="14" staggeraxis="x" staggerindex="even"/>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmx.StaggerAxis != StaggerAxisX || tmx.StaggerIndex != StaggerIndexEven || tmx.HexSideLength != 14 {
+		t.Errorf("unexpected stagger attributes: %s, %s, %d", tmx.StaggerAxis, tmx.StaggerIndex, tmx.HexSideLength)
+	}
+
+	tmj, err := DecodeJSON(strings.NewReader(`{"orientation":"staggered","width":1,"height":1,"staggeraxis":"y","staggerindex":"odd"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmj.StaggerAxis != StaggerAxisY || tmj.StaggerIndex != StaggerIndexOdd {
+		t.Errorf("unexpected stagger attributes: %s, %s", tmj.StaggerAxis, tmj.StaggerIndex)
+	}
+}
@@ -0,0 +1,93 @@
+package tmxmap
+
+import (
+	"image/color"
+	"strconv"
+)
+
+// Int returns the value of an int or object property.
+func (p Property) Int() (int, error) {
+	return strconv.Atoi(p.Value)
+}
+
+// Float returns the value of a float property.
+func (p Property) Float() (float64, error) {
+	return strconv.ParseFloat(p.Value, 64)
+}
+
+// Bool returns the value of a bool property.
+func (p Property) Bool() (bool, error) {
+	return strconv.ParseBool(p.Value)
+}
+
+// Color returns the value of a color property.
+func (p Property) Color() (color.RGBA, error) {
+	return ParseColor(p.Value)
+}
+
+// FindProperty returns the first property with the given name. Matching is case-sensitive.
+func FindProperty(props []Property, name string) (Property, bool) {
+	for _, p := range props {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Property{}, false
+}
+
+func findPropertyValue(props []Property, name string) (string, bool) {
+	p, ok := FindProperty(props, name)
+	return p.Value, ok
+}
+
+// Property returns the value of the map property with the given name.
+func (m *Map) Property(name string) (string, bool) {
+	return findPropertyValue(m.Properties, name)
+}
+
+// Property returns the value of the tileset property with the given name.
+func (ts *TileSet) Property(name string) (string, bool) {
+	return findPropertyValue(ts.Properties, name)
+}
+
+// Property returns the value of the tile property with the given name.
+func (t *Tile) Property(name string) (string, bool) {
+	return findPropertyValue(t.Properties, name)
+}
+
+// Properties returns the properties of the tile, held by its entry in the tileset. It returns nil for nil tiles and
+// tiles without an entry.
+func (t *TileInfo) Properties() []Property {
+	if t == nil || t.Nil || t.TileSet == nil {
+		return nil
+	}
+	if tile := t.TileSet.tile(t.ID); tile != nil {
+		return tile.Properties
+	}
+	return nil
+}
+
+// Property returns the value of the layer property with the given name.
+func (l *Layer) Property(name string) (string, bool) {
+	return findPropertyValue(l.Properties, name)
+}
+
+// Property returns the value of the object group property with the given name.
+func (og *ObjectGroup) Property(name string) (string, bool) {
+	return findPropertyValue(og.Properties, name)
+}
+
+// Property returns the value of the object property with the given name.
+func (o *Object) Property(name string) (string, bool) {
+	return findPropertyValue(o.Properties, name)
+}
+
+// Property returns the value of the image layer property with the given name.
+func (il *ImageLayer) Property(name string) (string, bool) {
+	return findPropertyValue(il.Properties, name)
+}
+
+// Property returns the value of the group property with the given name.
+func (g *Group) Property(name string) (string, bool) {
+	return findPropertyValue(g.Properties, name)
+}
@@ -0,0 +1,58 @@
+package tmxmap
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestPropertyValues(t *testing.T) {
+	if v, err := (Property{Type: "int", Value: "-42"}).Int(); err != nil || v != -42 {
+		t.Errorf("expected -42, got %d (%v)", v, err)
+	}
+	if v, err := (Property{Type: "float", Value: "1.5"}).Float(); err != nil || v != 1.5 {
+		t.Errorf("expected 1.5, got %f (%v)", v, err)
+	}
+	if v, err := (Property{Type: "bool", Value: "true"}).Bool(); err != nil || !v {
+		t.Errorf("expected true, got %t (%v)", v, err)
+	}
+	if v, err := (Property{Type: "color", Value: "#80ff0000"}).Color(); err != nil || v != (color.RGBA{R: 0xff, A: 0x80}) {
+		t.Errorf("expected semi-transparent red, got %v (%v)", v, err)
+	}
+	if _, err := (Property{Type: "int", Value: "abc"}).Int(); err == nil {
+		t.Errorf("expected an error for an invalid int")
+	}
+}
+
+func TestFindProperty(t *testing.T) {
+	layer := &Layer{Properties: []Property{{Name: "solid", Type: "bool", Value: "true"}}}
+	if v, ok := layer.Property("solid"); !ok || v != "true" {
+		t.Errorf("expected solid=true, got %q (%t)", v, ok)
+	}
+	if _, ok := layer.Property("Solid"); ok {
+		t.Errorf("property lookup should be case-sensitive")
+	}
+	if _, ok := FindProperty(nil, "solid"); ok {
+		t.Errorf("expected no property in an empty list")
+	}
+}
+
+func TestTileInfoProperties(t *testing.T) {
+	tmx, err := Load("assets/collection/objects.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tileSet := tmx.TileSets[0]
+	wall := &TileInfo{ID: 1, TileSet: tileSet}
+	if p, ok := FindProperty(wall.Properties(), "solid"); !ok || p.Value != "true" {
+		t.Errorf("expected solid=true, got %+v (%t)", p, ok)
+	}
+	if properties := (&TileInfo{ID: 0, TileSet: tileSet}).Properties(); properties != nil {
+		t.Errorf("expected no properties, got %v", properties)
+	}
+	if properties := (&TileInfo{ID: 5, TileSet: tileSet}).Properties(); properties != nil {
+		t.Errorf("expected no properties for a tile without an entry, got %v", properties)
+	}
+	if properties := NilTile.Properties(); properties != nil {
+		t.Errorf("expected no properties for the nil tile, got %v", properties)
+	}
+}
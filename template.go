@@ -0,0 +1,152 @@
+package tmxmap
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"path"
+)
+
+// objectTemplate is an object template file (.tx or .tj).
+type objectTemplate struct {
+	TileSet *TileSet `xml:"tileset" json:"tileset"`
+	Object  Object   `xml:"object" json:"object"`
+}
+
+func (m *Map) walkObjectGroups(objectGroups []ObjectGroup, groups []Group, fn func(og *ObjectGroup) error) error {
+	for i := range objectGroups {
+		if err := fn(&objectGroups[i]); err != nil {
+			return err
+		}
+	}
+	for i := range groups {
+		if err := m.walkObjectGroups(groups[i].ObjectGroups, groups[i].Groups, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeTemplates merges the template of each object into the object.
+func (m *Map) decodeTemplates(ld *loader, baseDir string) error {
+	if ld.fsys == nil {
+		return nil
+	}
+	templates := make(map[string]*objectTemplate)
+	return m.walkObjectGroups(m.ObjectGroups, m.Groups, func(og *ObjectGroup) error {
+		for i := range og.Objects {
+			object := &og.Objects[i]
+			if object.Template == "" {
+				continue
+			}
+			source := path.Join(baseDir, object.Template)
+			template, ok := templates[source]
+			if !ok {
+				var err error
+				if template, err = m.loadTemplate(ld, baseDir, source); err != nil {
+					return err
+				}
+				templates[source] = template
+			}
+			if err := object.applyTemplate(&template.Object); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// loadTemplate loads a template file. The GID of a tile template is remapped to the map tileset sharing the same
+// source as the template tileset.
+func (m *Map) loadTemplate(ld *loader, baseDir, source string) (*objectTemplate, error) {
+	if err := ld.checkContext(source); err != nil {
+		return nil, err
+	}
+	file, err := ld.fsys.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	template := &objectTemplate{}
+	if isJSON(source) {
+		err = json.NewDecoder(file).Decode(template)
+	} else {
+		err = ld.newXMLDecoder(file).Decode(template)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if template.TileSet != nil && template.Object.GID != 0 {
+		tileSetSource := path.Join(path.Dir(source), template.TileSet.Source)
+		for _, ts := range m.TileSets {
+			if ts.Source != "" && path.Join(baseDir, ts.Source) == tileSetSource {
+				template.Object.GID += int(ts.FirstGID) - int(template.TileSet.FirstGID)
+				break
+			}
+		}
+	}
+	return template, nil
+}
+
+// applyTemplate merges the template into the object. Attributes, properties and shapes of the object override the
+// ones of the template. The object as declared is kept so that encoding does not inline the template.
+func (o *Object) applyTemplate(template *Object) error {
+	type object Object
+	instance := *o
+	merged := object(*template)
+	merged.Properties = append([]Property(nil), template.Properties...)
+
+	// Decode the object attributes on top of the template ones
+	var buf bytes.Buffer
+	encoder := xml.NewEncoder(&buf)
+	start := xml.StartElement{Name: xml.Name{Local: "object"}, Attr: o.attrs}
+	if err := encoder.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := encoder.EncodeToken(start.End()); err != nil {
+		return err
+	}
+	if err := encoder.Flush(); err != nil {
+		return err
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &merged); err != nil {
+		return err
+	}
+
+	for _, p := range o.Properties {
+		replaced := false
+		for i := range merged.Properties {
+			if merged.Properties[i].Name == p.Name {
+				merged.Properties[i] = p
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged.Properties = append(merged.Properties, p)
+		}
+	}
+	if o.Ellipse != nil || o.Point != nil || len(o.Polygons) > 0 || len(o.PolyLines) > 0 || o.Text != nil {
+		merged.Ellipse, merged.Point, merged.Polygons, merged.PolyLines, merged.Text = o.Ellipse, o.Point, o.Polygons, o.PolyLines, o.Text
+	}
+	merged.attrs = o.attrs
+	merged.instance = &instance
+	*o = Object(merged)
+
+	// A class set on the object overrides the type inherited from the template
+	if hasAttr(o.attrs, "class") && !hasAttr(o.attrs, "type") {
+		o.Type = o.Class
+	}
+	return nil
+}
+
+func hasAttr(attrs []xml.Attr, name string) bool {
+	for _, attr := range attrs {
+		if attr.Name.Local == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package tmxmap
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestTemplate(t *testing.T) {
+	tmx, err := Load("assets/templates/track1_bg.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chest, locked := tmx.ObjectGroups[0].Objects[0], tmx.ObjectGroups[0].Objects[1]
+	if chest.Name != "chest" || chest.Type != "container" || chest.X != 8 || chest.Y != 16 || chest.Width != 8 {
+		t.Errorf("unexpected chest: %+v", chest)
+	}
+	if chest.GID != 9 {
+		t.Errorf("expected template GID to be remapped to 9, got %d", chest.GID)
+	}
+	if v, _ := chest.Property("locked"); v != "false" {
+		t.Errorf("expected chest to be unlocked, got %q", v)
+	}
+	if locked.Name != "locked chest" || locked.X != 24 {
+		t.Errorf("unexpected locked chest: %+v", locked)
+	}
+	if v, _ := locked.Property("locked"); v != "true" {
+		t.Errorf("expected chest to be locked, got %q", v)
+	}
+	if v, _ := locked.Property("gold"); v != "10" {
+		t.Errorf("expected template property gold=10, got %q", v)
+	}
+}
+
+func TestJSONTemplate(t *testing.T) {
+	fsys := fstest.MapFS{
+		"zone.tj": &fstest.MapFile{Data: []byte(`{"type":"template","object":{"name":"zone","width":8,"height":8}}`)},
+		"map.tmj": &fstest.MapFile{Data: []byte(`{"width":1,"height":1,"tilewidth":8,"tileheight":8,"layers":[
+ {"type":"objectgroup","name":"Objects","objects":[{"id":1,"template":"zone.tj","x":4,"y":2,"ellipse":true}]}]}`)},
+	}
+	tmx, err := LoadFS(fsys, "map.tmj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	zone := tmx.ObjectGroups[0].Objects[0]
+	if zone.Name != "zone" || zone.X != 4 || zone.Width != 8 || zone.Ellipse == nil || len(zone.UnknownAttrs) != 0 {
+		t.Errorf("unexpected zone: %+v", zone)
+	}
+
+	var buf strings.Builder
+	if err := tmx.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{`<object id="1" template="zone.tj" x="4" y="2">`, `<ellipse></ellipse>`} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("encoded map should contain %s:\n%s", s, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), `ellipse="`) {
+		t.Errorf("the ellipse should not be encoded as an attribute:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,192 @@
+package tmxmap
+
+import (
+	"image"
+	"time"
+)
+
+// Transform returns the flips and clockwise rotation, in degrees, equivalent to the tile flip flags. Flips must be
+// applied before the rotation. The diagonal flip is expressed as a rotation, combined with a horizontal flip when
+// the result is mirrored.
+func (t *TileInfo) Transform() (flipH, flipV bool, rotation int) {
+	if !t.DiagonalFlip {
+		return t.HorizontalFlip, t.VerticalFlip, 0
+	}
+	switch {
+	case t.HorizontalFlip && t.VerticalFlip:
+		return true, false, 90
+	case t.HorizontalFlip:
+		return false, false, 90
+	case t.VerticalFlip:
+		return false, false, 270
+	}
+	return true, false, 270
+}
+
+// tile returns the tile of the tileset with the given local ID, or nil if the tileset has no entry for it.
+func (ts *TileSet) tile(id GID) *Tile {
+	for i := range ts.Tiles {
+		if ts.Tiles[i].ID == id {
+			return &ts.Tiles[i]
+		}
+	}
+	return nil
+}
+
+// imageColumns returns the number of tile columns fitting in the tileset image, for tilesets saved without their
+// column count. The width of the decoded image is used when the image element has none.
+func (ts *TileSet) imageColumns() int {
+	if ts.Image == nil || ts.TileWidth+ts.Spacing <= 0 {
+		return 0
+	}
+	width := ts.Image.Width
+	if width == 0 && ts.Image.Image != nil {
+		width = ts.Image.Image.Bounds().Dx()
+	}
+	return max((width-2*ts.Margin+ts.Spacing)/(ts.TileWidth+ts.Spacing), 0)
+}
+
+// SourceRect returns the bounds of the tile within its tileset image. For collection of images tilesets, it returns
+// the bounds of the tile own image. It returns an empty rectangle for nil tiles.
+func (t *TileInfo) SourceRect() image.Rectangle {
+	if t.Nil || t.TileSet == nil {
+		return image.Rectangle{}
+	}
+	ts := t.TileSet
+	if ts.Image == nil {
+		tile := ts.tile(t.ID)
+		if tile == nil {
+			return image.Rectangle{}
+		}
+		if tile.Image.Image != nil {
+			return tile.Image.Image.Bounds()
+		}
+		return image.Rect(0, 0, tile.Image.Width, tile.Image.Height)
+	}
+
+	return ts.gridRect(t.ID)
+}
+
+// gridRect returns the bounds of the grid cell of a tile within the tileset image, or an empty rectangle when the
+// number of columns is unknown.
+func (ts *TileSet) gridRect(id GID) image.Rectangle {
+	columns := ts.Columns
+	if columns == 0 {
+		columns = ts.imageColumns()
+	}
+	if columns <= 0 {
+		return image.Rectangle{}
+	}
+	x := ts.Margin + int(id)%columns*(ts.TileWidth+ts.Spacing)
+	y := ts.Margin + int(id)/columns*(ts.TileHeight+ts.Spacing)
+	return image.Rect(x, y, x+ts.TileWidth, y+ts.TileHeight)
+}
+
+// TileBounds returns the image a tile of the tileset is drawn from and its bounds within it, given the local ID of
+// the tile. Single image tilesets return their image and the grid cell of the tile, collection of images tilesets
+// the tile own image and its bounds. The image is nil when images are not loaded. It returns false for IDs outside
+// of the tileset.
+func (ts *TileSet) TileBounds(id GID) (image.Rectangle, image.Image, bool) {
+	if ts.Image == nil {
+		tile := ts.tile(id)
+		if tile == nil {
+			return image.Rectangle{}, nil, false
+		}
+		if tile.Image.Image != nil {
+			return tile.Image.Image.Bounds(), tile.Image.Image, true
+		}
+		return image.Rect(0, 0, tile.Image.Width, tile.Image.Height), nil, true
+	}
+
+	if ts.Tilecount > 0 && int(id) >= ts.Tilecount {
+		return image.Rectangle{}, nil, false
+	}
+	r := ts.gridRect(id)
+	if r.Empty() {
+		return image.Rectangle{}, nil, false
+	}
+	return r, ts.Image.Image, true
+}
+
+// Image returns the pixels of the tile. It slices the tileset image, or returns the tile own image for collection of
+// images tilesets. It returns nil for nil tiles, when images are not loaded, or when the tileset image does not
+// support SubImage.
+func (t *TileInfo) Image() image.Image {
+	if t.Nil || t.TileSet == nil {
+		return nil
+	}
+	ts := t.TileSet
+	if ts.Image == nil {
+		if tile := ts.tile(t.ID); tile != nil {
+			return tile.Image.Image
+		}
+		return nil
+	}
+	img, ok := ts.Image.Image.(interface {
+		SubImage(r image.Rectangle) image.Image
+	})
+	if !ok {
+		return nil
+	}
+	return img.SubImage(t.SourceRect())
+}
+
+// TileByGID resolves a GID, flip flags included, to its tileset and local tile ID. Tilesets are indexed by first
+// GID when the map is loaded, maps built by hand are scanned instead.
+func (m *Map) TileByGID(gid GID) (*TileInfo, error) {
+	return m.decodeGID(gid)
+}
+
+// TileSet returns the first tileset with the given name.
+func (m *Map) TileSet(name string) (*TileSet, bool) {
+	for _, ts := range m.TileSets {
+		if ts.Name == name {
+			return ts, true
+		}
+	}
+	return nil, false
+}
+
+// FrameAt returns the GID of the frame an animated tile displays once elapsed time has passed since the animation
+// started, looping over the frames. The flip flags of the tile are kept. Tiles without animation return their own
+// GID, nil tiles 0.
+func (t *TileInfo) FrameAt(elapsed time.Duration) GID {
+	gid := t.gid()
+	if gid == 0 {
+		return 0
+	}
+	tile := t.TileSet.tile(t.ID)
+	if tile == nil || !tile.IsAnimated() {
+		return gid
+	}
+
+	var total time.Duration
+	for _, frame := range tile.Animation {
+		total += time.Duration(frame.Duration) * time.Millisecond
+	}
+	frameID := tile.Animation[0].TileID
+	if total > 0 {
+		elapsed %= total
+		if elapsed < 0 {
+			elapsed += total
+		}
+		for _, frame := range tile.Animation {
+			elapsed -= time.Duration(frame.Duration) * time.Millisecond
+			if elapsed < 0 {
+				frameID = frame.TileID
+				break
+			}
+		}
+	}
+	return gid - t.ID + frameID
+}
+
+// tileRange returns the number of GIDs covered by the tileset: its tile count, or the highest tile ID plus one for
+// image collections whose tiles were removed. It is 0 when unknown, as for unresolved external tilesets.
+func (ts *TileSet) tileRange() int {
+	n := ts.Tilecount
+	for _, tile := range ts.Tiles {
+		n = max(n, int(tile.ID)+1)
+	}
+	return n
+}
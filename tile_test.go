@@ -0,0 +1,356 @@
+package tmxmap
+
+import (
+	"fmt"
+	"image"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestTransform(t *testing.T) {
+	// Tiled applies the diagonal flip first, then the horizontal and vertical flips.
+	tiled := func(tile *TileInfo, p image.Point) image.Point {
+		if tile.DiagonalFlip {
+			p.X, p.Y = p.Y, p.X
+		}
+		if tile.HorizontalFlip {
+			p.X = -p.X
+		}
+		if tile.VerticalFlip {
+			p.Y = -p.Y
+		}
+		return p
+	}
+	transform := func(flipH, flipV bool, rotation int, p image.Point) image.Point {
+		if flipH {
+			p.X = -p.X
+		}
+		if flipV {
+			p.Y = -p.Y
+		}
+		for i := 0; i < rotation/90; i++ {
+			p.X, p.Y = -p.Y, p.X
+		}
+		return p
+	}
+
+	for flags := 0; flags < 8; flags++ {
+		tile := &TileInfo{HorizontalFlip: flags&1 != 0, VerticalFlip: flags&2 != 0, DiagonalFlip: flags&4 != 0}
+		flipH, flipV, rotation := tile.Transform()
+		name := fmt.Sprintf("h=%t v=%t d=%t", tile.HorizontalFlip, tile.VerticalFlip, tile.DiagonalFlip)
+		if rotation%90 != 0 || rotation < 0 || rotation >= 360 {
+			t.Errorf("%s: invalid rotation %d", name, rotation)
+		}
+		for _, p := range []image.Point{{1, 2}, {-3, 1}, {2, -5}} {
+			if expected, actual := tiled(tile, p), transform(flipH, flipV, rotation, p); expected != actual {
+				t.Errorf("%s: %v: expected %v, got %v", name, p, expected, actual)
+			}
+		}
+	}
+}
+
+func TestSourceRect(t *testing.T) {
+	ts := &TileSet{TileWidth: 16, TileHeight: 16, Spacing: 2, Margin: 1, Columns: 4, Image: &Image{Width: 71, Height: 71}}
+	tests := []struct {
+		tile     *TileInfo
+		expected image.Rectangle
+	}{
+		{&TileInfo{ID: 0, TileSet: ts}, image.Rect(1, 1, 17, 17)},
+		{&TileInfo{ID: 5, TileSet: ts}, image.Rect(19, 19, 35, 35)},
+		{&TileInfo{ID: 15, TileSet: ts}, image.Rect(55, 55, 71, 71)},
+		{NilTile, image.Rectangle{}},
+	}
+	for _, test := range tests {
+		if r := test.tile.SourceRect(); r != test.expected {
+			t.Errorf("tile %d: expected %v, got %v", test.tile.ID, test.expected, r)
+		}
+	}
+
+	collection := &TileSet{Tiles: []Tile{{ID: 3, Image: Image{Width: 24, Height: 40}}}}
+	if r := (&TileInfo{ID: 3, TileSet: collection}).SourceRect(); r != image.Rect(0, 0, 24, 40) {
+		t.Errorf("unexpected collection tile bounds: %v", r)
+	}
+}
+
+func TestTileBounds(t *testing.T) {
+	tmx, err := Load("assets/external/track1_bg.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := tmx.TileSets[0]
+	r, img, ok := ts.TileBounds(17)
+	if !ok || r != image.Rect(8, 8, 16, 16) || img != ts.Image.Image {
+		t.Errorf("unexpected tile bounds: %v, %v", r, ok)
+	}
+	if _, _, ok := ts.TileBounds(GID(ts.Tilecount)); ok {
+		t.Errorf("tile %d should be out of range", ts.Tilecount)
+	}
+
+	collection := &TileSet{Tiles: []Tile{{ID: 3, Image: Image{Width: 24, Height: 40}}}}
+	if r, img, ok := collection.TileBounds(3); !ok || r != image.Rect(0, 0, 24, 40) || img != nil {
+		t.Errorf("unexpected collection tile bounds: %v, %v, %v", r, img, ok)
+	}
+	if _, _, ok := collection.TileBounds(0); ok {
+		t.Errorf("tile 0 should not be part of the collection")
+	}
+}
+
+func TestTileImage(t *testing.T) {
+	tmx, err := Load("assets/external/track1_bg.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tile := &TileInfo{ID: 17, TileSet: tmx.TileSets[0]}
+	img := tile.Image()
+	if img == nil {
+		t.Fatalf("tile image should not be null")
+	}
+	if img.Bounds() != image.Rect(8, 8, 16, 16) {
+		t.Errorf("unexpected tile image bounds: %v", img.Bounds())
+	}
+	if NilTile.Image() != nil {
+		t.Errorf("nil tile image should be null")
+	}
+}
+
+func TestGIDFlags(t *testing.T) {
+	gid := GID(0xA0000005)
+	if h, v, d := gid.Flags(); !h || v || !d {
+		t.Errorf("unexpected flags: %t %t %t", h, v, d)
+	}
+	if gid.Clear() != 5 {
+		t.Errorf("expected 5, got %d", gid.Clear())
+	}
+}
+
+func TestTransformations(t *testing.T) {
+	tmx, err := Decode(strings.NewReader(`<map width="1" height="1">
+ <tileset firstgid="1" name="terrain" tilewidth="8" tileheight="8" tilecount="0" columns="0">
+  <transformations hflip="1" vflip="0" rotate="1" preferuntransformed="1"/>
+ </tileset>
+</map>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Transformations{HFlip: true, Rotate: true, PreferUntransformed: true}
+	if tr := tmx.TileSets[0].Transformations; tr == nil || *tr != expected {
+		t.Errorf("expected %+v, got %+v", expected, tr)
+	}
+
+	var buf strings.Builder
+	if err := tmx.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `<transformations hflip="1" rotate="1" preferuntransformed="1"></transformations>`) {
+		t.Errorf("unexpected encoding: %s", buf.String())
+	}
+}
+
+func TestTileByGID(t *testing.T) {
+	tmx, err := Load("assets/templates/track1_bg.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	built := &Map{TileSets: tmx.TileSets}
+
+	for _, m := range []*Map{tmx, built} {
+		tile, err := m.TileByGID(6 | horizontalFlip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tile.TileSet != m.TileSets[1] || tile.ID != 1 || !tile.HorizontalFlip {
+			t.Errorf("unexpected tile: %+v", tile)
+		}
+		if tile, err := m.TileByGID(4); err != nil || tile.TileSet != m.TileSets[0] || tile.ID != 3 {
+			t.Errorf("unexpected tile: %+v (%v)", tile, err)
+		}
+		if tile, err := m.TileByGID(0); err != nil || !tile.Nil {
+			t.Errorf("expected the nil tile, got %+v (%v)", tile, err)
+		}
+	}
+	if _, err := (&Map{}).TileByGID(1); err == nil || err.Error() != "invalid tile GID: 1" {
+		t.Errorf("expected an invalid GID error without layer, got %v", err)
+	}
+}
+
+func TestLoadTileSet(t *testing.T) {
+	for _, name := range []string{"assets/external/track1_bg.tsx", "assets/json/track1_bg.tsj"} {
+		ts, err := LoadTileSet(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ts.Name != "track1_bg" || ts.Tilecount != 32 || ts.Columns != 16 {
+			t.Errorf("%s: unexpected tileset: %+v", name, ts)
+		}
+		if ts.Source != "" || ts.Image == nil || ts.Image.Image == nil {
+			t.Errorf("%s: tileset image should be loaded", name)
+		}
+		skipped, err := LoadOptions{SkipExternalTileSets: true}.LoadTileSet(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(skipped, ts) {
+			t.Errorf("%s: SkipExternalTileSets should not apply to the loaded tileset: %+v", name, skipped)
+		}
+	}
+	if _, err := LoadTileSet("assets/external/missing.tsx"); err == nil {
+		t.Errorf("expected an error for a missing tileset")
+	}
+}
+
+func TestFillMode(t *testing.T) {
+	tmx, err := Decode(strings.NewReader(`<map width="1" height="1">
+ <tileset firstgid="1" name="stretched"/>
+ <tileset firstgid="2" name="fitted" fillmode="preserve-aspect-fit"/>
+</map>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmx.TileSets[0].FillMode != "stretch" || tmx.TileSets[1].FillMode != "preserve-aspect-fit" {
+		t.Errorf("unexpected fill modes: %s, %s", tmx.TileSets[0].FillMode, tmx.TileSets[1].FillMode)
+	}
+
+	var buf strings.Builder
+	if err := tmx.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(buf.String(), "fillmode") != 1 || !strings.Contains(buf.String(), `fillmode="preserve-aspect-fit"`) {
+		t.Errorf("only the non default fill mode should be encoded:\n%s", buf.String())
+	}
+
+	for _, name := range []string{"assets/external/track1_bg.tmx", "assets/json/track1_bg.tmj"} {
+		external, err := Load(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if external.TileSets[0].FillMode != "stretch" {
+			t.Errorf("%s: unexpected fill mode: %s", name, external.TileSets[0].FillMode)
+		}
+	}
+}
+
+func TestColumnsFallback(t *testing.T) {
+	tmx, err := Decode(strings.NewReader(`<map width="1" height="1">
+ <tileset firstgid="1" name="old" tilewidth="16" tileheight="16" spacing="1" margin="2">
+  <image source="tiles.png" width="72" height="36"/>
+ </tileset>
+</map>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tileSet := tmx.TileSets[0]
+	if tileSet.Columns != 4 {
+		t.Errorf("expected 4 columns, got %d", tileSet.Columns)
+	}
+	if rect := (&TileInfo{ID: 5, TileSet: tileSet}).SourceRect(); rect != image.Rect(19, 19, 35, 35) {
+		t.Errorf("unexpected source rect: %v", rect)
+	}
+
+	decoded := &TileSet{TileWidth: 16, Image: &Image{Image: image.NewRGBA(image.Rect(0, 0, 64, 16))}}
+	if columns := decoded.imageColumns(); columns != 4 {
+		t.Errorf("expected the decoded image width to be used, got %d columns", columns)
+	}
+	if columns := (&TileSet{Image: &Image{Width: 64}}).imageColumns(); columns != 0 {
+		t.Errorf("expected no columns without a tile width, got %d", columns)
+	}
+}
+
+func TestFrameAt(t *testing.T) {
+	tileSet := &TileSet{FirstGID: 10, Tiles: []Tile{{ID: 2, Animation: []Frame{{TileID: 2, Duration: 100}, {TileID: 5, Duration: 50}, {TileID: 7, Duration: 250}}}}}
+	animated := &TileInfo{ID: 2, TileSet: tileSet}
+	tests := []struct {
+		elapsed  time.Duration
+		expected GID
+	}{
+		{0, 12},
+		{99 * time.Millisecond, 12},
+		{100 * time.Millisecond, 15},
+		{149 * time.Millisecond, 15},
+		{150 * time.Millisecond, 17},
+		{400 * time.Millisecond, 12},
+		{1300 * time.Millisecond, 15},
+		{-50 * time.Millisecond, 17},
+	}
+	for _, test := range tests {
+		if gid := animated.FrameAt(test.elapsed); gid != test.expected {
+			t.Errorf("%v: expected GID %d, got %d", test.elapsed, test.expected, gid)
+		}
+	}
+
+	flipped := &TileInfo{ID: 2, TileSet: tileSet, HorizontalFlip: true}
+	if gid := flipped.FrameAt(120 * time.Millisecond); gid != 15|horizontalFlip {
+		t.Errorf("expected the flip flags to be kept, got %d", gid)
+	}
+	if gid := (&TileInfo{ID: 3, TileSet: tileSet}).FrameAt(time.Second); gid != 13 {
+		t.Errorf("expected a static tile to return its own GID, got %d", gid)
+	}
+	if gid := NilTile.FrameAt(time.Second); gid != 0 {
+		t.Errorf("expected 0 for the nil tile, got %d", gid)
+	}
+}
+
+func TestTileAnimation(t *testing.T) {
+	fsys := fstest.MapFS{
+		"map.tmx": &fstest.MapFile{Data: []byte(`<map width="1" height="1"><tileset firstgid="1" source="water.tsx"/></map>`)},
+		"water.tsx": &fstest.MapFile{Data: []byte(`<tileset name="water" tilewidth="8" tileheight="8" tilecount="4" columns="4">
+ <tile id="1">
+  <animation>
+   <frame tileid="1" duration="100"/>
+   <frame tileid="2" duration="150"/>
+   <frame tileid="3" duration="200"/>
+  </animation>
+ </tile>
+</tileset>`)},
+	}
+	tmx, err := LoadFS(fsys, "map.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tile := tmx.TileSets[0].tile(1)
+	expected := []Frame{{TileID: 1, Duration: 100}, {TileID: 2, Duration: 150}, {TileID: 3, Duration: 200}}
+	if tile == nil || !tile.IsAnimated() || !reflect.DeepEqual(tile.Animation, expected) {
+		t.Errorf("expected frames %v, got %+v", expected, tile)
+	}
+}
+
+func TestTileCollision(t *testing.T) {
+	tmx, err := Decode(strings.NewReader(`<map width="1" height="1">
+ <tileset firstgid="1" name="walls" tilewidth="16" tileheight="16" tilecount="4" columns="4">
+  <tile id="2">
+   <objectgroup draworder="index" id="2">
+    <object id="1" x="0" y="8" width="16" height="8"/>
+    <object id="2" x="4" y="2.5">
+     <polygon points="0,0 8,0 4,6"/>
+    </object>
+   </objectgroup>
+  </tile>
+ </tileset>
+</map>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tile := tmx.TileSets[0].tile(2)
+	if tile == nil || tile.ObjectGroup == nil || len(tile.ObjectGroup.Objects) != 2 {
+		t.Fatalf("expected a collision group with 2 objects, got %+v", tile)
+	}
+	rect, polygon := tile.ObjectGroup.Objects[0], tile.ObjectGroup.Objects[1]
+	if rect.Kind() != RectangleObject || rect.X != 0 || rect.Y != 8 || rect.Width != 16 || rect.Height != 8 {
+		t.Errorf("unexpected rectangle: %+v", rect)
+	}
+	if polygon.Kind() != PolygonObject || polygon.X != 4 || polygon.Y != 2.5 {
+		t.Errorf("unexpected polygon: %+v", polygon)
+	}
+	points, err := polygon.Polygons[0].Vertices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []Point{{0, 0}, {8, 0}, {4, 6}}; !reflect.DeepEqual(points, expected) {
+		t.Errorf("expected vertices %v, got %v", expected, points)
+	}
+}
@@ -1,22 +1,35 @@
 package tmxmap
 
 import (
+	"bufio"
 	"bytes"
 	"compress/gzip"
 	"compress/zlib"
+	"context"
 	"encoding/base64"
+	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"image"
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
 	"io"
+	"io/fs"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode"
+
+	"github.com/klauspost/compress/zstd"
 )
 
 const (
@@ -29,59 +42,219 @@ var NilTile = &TileInfo{Nil: true}
 
 type GID uint32
 
+// Flags returns the flip flags stored in the high bits of the GID.
+func (gid GID) Flags() (horizontal, vertical, diagonal bool) {
+	return gid&horizontalFlip != 0, gid&verticalFlip != 0, gid&diagonalFlip != 0
+}
+
+// Clear returns the GID without its flip flags.
+func (gid GID) Clear() GID {
+	return gid &^ (horizontalFlip | verticalFlip | diagonalFlip)
+}
+
 // Map represents the TMX Map Format https://doc.mapeditor.org/en/stable/reference/tmx-map-format/
 type Map struct {
-	Version         string        `xml:"version,attr"`
-	TiledVersion    string        `xml:"tiledversion,attr"`
-	Orientation     string        `xml:"orientation,attr"`
-	RenderOrder     string        `xml:"renderorder,attr"`
-	Width           int           `xml:"width,attr"`
-	Height          int           `xml:"height,attr"`
-	TileWidth       int           `xml:"tilewidth,attr"`
-	TileHeight      int           `xml:"tileheight,attr"`
-	HexSideLength   int           `xml:"hexsidelength,attr"`
-	StaggerAxis     int           `xml:"staggeraxis,attr"`
-	StaggerIndex    int           `xml:"staggerindex,attr"`
-	BackgroundColor string        `xml:"backgroundcolor,attr"`
-	NextLayerID     int           `xml:"nextlayerid,attr"`
-	NextObjectID    int           `xml:"nextobjectid,attr"`
-	Properties      []Property    `xml:"properties>property"`
-	TileSets        []TileSet     `xml:"tileset"`
-	Layers          []Layer       `xml:"layer"`
-	ObjectGroups    []ObjectGroup `xml:"objectgroup"`
+	Version          string          `xml:"version,attr" json:"version"`
+	TiledVersion     string          `xml:"tiledversion,attr" json:"tiledversion"`
+	Class            string          `xml:"class,attr" json:"class"`
+	Orientation      string          `xml:"orientation,attr" json:"orientation"`
+	RenderOrder      string          `xml:"renderorder,attr" json:"renderorder"`
+	Width            int             `xml:"width,attr" json:"width"`
+	Height           int             `xml:"height,attr" json:"height"`
+	TileWidth        int             `xml:"tilewidth,attr" json:"tilewidth"`
+	TileHeight       int             `xml:"tileheight,attr" json:"tileheight"`
+	Infinite         bool            `xml:"infinite,attr" json:"infinite"`
+	HexSideLength    int             `xml:"hexsidelength,attr" json:"hexsidelength"`
+	StaggerAxis      StaggerAxis     `xml:"staggeraxis,attr" json:"staggeraxis"`
+	StaggerIndex     StaggerIndex    `xml:"staggerindex,attr" json:"staggerindex"`
+	BackgroundColor  string          `xml:"backgroundcolor,attr" json:"backgroundcolor"`
+	NextLayerID      int             `xml:"nextlayerid,attr" json:"nextlayerid"`
+	NextObjectID     int             `xml:"nextobjectid,attr" json:"nextobjectid"`
+	ParallaxOriginX  float64         `xml:"parallaxoriginx,attr" json:"parallaxoriginx"`
+	ParallaxOriginY  float64         `xml:"parallaxoriginy,attr" json:"parallaxoriginy"`
+	CompressionLevel int             `xml:"compressionlevel,attr" json:"compressionlevel"`
+	EditorSettings   *EditorSettings `xml:"editorsettings" json:"-"`
+	Properties       []Property      `xml:"properties>property" json:"properties"`
+	TileSets         []*TileSet      `xml:"tileset" json:"tilesets"`
+	Layers           []Layer         `xml:"layer" json:"-"`
+	ObjectGroups     []ObjectGroup   `xml:"objectgroup" json:"-"`
+	ImageLayers      []ImageLayer    `xml:"imagelayer" json:"-"`
+	Groups           []Group         `xml:"group" json:"-"`
+	Unknown          `json:"-"`
+
+	// InvalidGIDs lists the GIDs replaced by NilTile when loading with LoadOptions.IgnoreInvalidGID.
+	InvalidGIDs []*InvalidGIDError `xml:"-" json:"-"`
+
+	// Warnings lists the problems worked around while decoding the map.
+	Warnings []string `xml:"-" json:"-"`
+
+	// tileSetOrder holds the tileset indexes sorted by first GID.
+	tileSetOrder []int
+	// fsys and baseDir are where the map was loaded from, if it was loaded from a file system.
+	fsys    fs.FS
+	baseDir string
+	// options are the options the map was loaded with, used to resolve tiles lazily.
+	options LoadOptions
+	// unmarshalled tells whether the map was decoded from a document, its CompressionLevel then defaulting to -1.
+	unmarshalled bool
+}
+
+// UnmarshalXML applies Tiled's defaults for the attributes missing from the element.
+func (m *Map) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type tileMap Map
+	v := tileMap{CompressionLevel: -1, unmarshalled: true}
+	if err := d.DecodeElement(&v, &start); err != nil {
+		return err
+	}
+	*m = Map(v)
+	return nil
+}
+
+// EditorSettings holds the settings Tiled saves along with the map for editing it.
+type EditorSettings struct {
+	ChunkSize *ChunkSize `xml:"chunksize"`
+	Export    *Export    `xml:"export"`
+}
+
+// ChunkSize is the size of the chunks of infinite maps, 16x16 tiles when not set.
+type ChunkSize struct {
+	Width  int `xml:"width,attr"`
+	Height int `xml:"height,attr"`
+}
+
+// Export is the last export target of the map.
+type Export struct {
+	Target string `xml:"target,attr"`
+	Format string `xml:"format,attr"`
 }
 
 type Property struct {
-	Name  string `xml:"name,attr"`
-	Value string `xml:"value,attr"`
+	Name  string `xml:"name,attr" json:"name"`
+	Type  string `xml:"type,attr,omitempty" json:"type"`
+	Value string `xml:"value,attr" json:"value"`
+}
+
+// Unknown holds the attributes and child elements this package does not support, so that encoding a map does not
+// lose them.
+type Unknown struct {
+	UnknownAttrs    []xml.Attr       `xml:",any,attr"`
+	UnknownElements []UnknownElement `xml:",any"`
+}
+
+// UnknownElement is an element kept as is.
+type UnknownElement struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	InnerXML []byte     `xml:",innerxml"`
 }
 
 type TileSet struct {
-	FirstGID   GID        `xml:"firstgid,attr"`
-	Source     string     `xml:"source,attr"`
-	Name       string     `xml:"name,attr"`
-	TileWidth  int        `xml:"tilewidth,attr"`
-	TileHeight int        `xml:"tileheight,attr"`
-	Spacing    int        `xml:"spacing,attr"`
-	Margin     int        `xml:"margin,attr"`
-	Properties []Property `xml:"properties>property"`
-	Image      *Image      `xml:"image"`
-	Tiles      []Tile     `xml:"tile"`
-	Tilecount  int        `xml:"tilecount,attr"`
-	Columns    int        `xml:"columns,attr"`
+	FirstGID   GID        `xml:"firstgid,attr" json:"firstgid"`
+	Source     string     `xml:"source,attr" json:"source"`
+	Name       string     `xml:"name,attr" json:"name"`
+	Class      string     `xml:"class,attr" json:"class"`
+	TileWidth  int        `xml:"tilewidth,attr" json:"tilewidth"`
+	TileHeight int        `xml:"tileheight,attr" json:"tileheight"`
+	Spacing    int        `xml:"spacing,attr" json:"spacing"`
+	Margin     int        `xml:"margin,attr" json:"margin"`
+	Properties []Property `xml:"properties>property" json:"properties"`
+	Image      *Image     `xml:"image" json:"-"`
+	Tiles      []Tile     `xml:"tile" json:"tiles"`
+	Tilecount  int        `xml:"tilecount,attr" json:"tilecount"`
+	Columns    int        `xml:"columns,attr" json:"columns"`
+	// ObjectAlignment is the anchor of the tile objects using the tileset: unspecified, topleft, top, topright,
+	// left, center, right, bottomleft, bottom or bottomright. Unspecified resolves to bottom on isometric maps and
+	// bottomleft otherwise, see Map.ObjectAlignment.
+	ObjectAlignment string `xml:"objectalignment,attr" json:"objectalignment"`
+	// FillMode tells how tiles are drawn in tile objects of a different size: stretch or preserve-aspect-fit.
+	FillMode        string           `xml:"fillmode,attr" json:"fillmode"`
+	TileOffset      TileOffset       `xml:"tileoffset" json:"tileoffset"`
+	Grid            *Grid            `xml:"grid" json:"grid"`
+	Transformations *Transformations `xml:"transformations" json:"transformations"`
+	WangSets        []WangSet        `xml:"wangsets>wangset" json:"wangsets"`
+	Unknown         `json:"-"`
+
+	// dir is the directory the tileset images are resolved against.
+	dir string
+}
+
+// UnmarshalXML applies Tiled's defaults for the attributes missing from the element. The tileset is decoded in
+// place, so that external tilesets are merged into the tileset referencing them.
+func (ts *TileSet) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type tileSet TileSet
+	if ts.ObjectAlignment == "" {
+		ts.ObjectAlignment = "unspecified"
+	}
+	if ts.FillMode == "" {
+		ts.FillMode = "stretch"
+	}
+	return d.DecodeElement((*tileSet)(ts), &start)
+}
+
+// TileOffset is the offset in pixels applied when drawing tiles of a tileset.
+type TileOffset struct {
+	X int `xml:"x,attr" json:"x"`
+	Y int `xml:"y,attr" json:"y"`
+}
+
+// Grid describes how tiles of a tileset are aligned when used as tile objects, orientation being "orthogonal" or
+// "isometric".
+type Grid struct {
+	Orientation string `xml:"orientation,attr" json:"orientation"`
+	Width       int    `xml:"width,attr" json:"width"`
+	Height      int    `xml:"height,attr" json:"height"`
+}
+
+// Transformations lists the transformations automatic tiling may apply to the tiles of a tileset.
+type Transformations struct {
+	HFlip               bool `xml:"hflip,attr" json:"hflip"`
+	VFlip               bool `xml:"vflip,attr" json:"vflip"`
+	Rotate              bool `xml:"rotate,attr" json:"rotate"`
+	PreferUntransformed bool `xml:"preferuntransformed,attr" json:"preferuntransformed"`
 }
 
 type Image struct {
-	Source string `xml:"source,attr"`
-	Trans  string `xml:"trans,attr"`
-	Width  int    `xml:"width,attr"`
-	Height int    `xml:"height,attr"`
-	Image  image.Image
+	Source string      `xml:"source,attr"`
+	Format string      `xml:"format,attr"`
+	Trans  string      `xml:"trans,attr"`
+	Width  int         `xml:"width,attr"`
+	Height int         `xml:"height,attr"`
+	Data   *Data       `xml:"data"`
+	Image  image.Image `xml:"-"`
 }
 
 type Tile struct {
-	ID    GID   `xml:"id,attr"`
-	Image Image `xml:"image"`
+	ID          GID          `xml:"id,attr" json:"id"`
+	Type        string       `xml:"type,attr" json:"type"`
+	Class       string       `xml:"class,attr" json:"class"`
+	Probability float64      `xml:"probability,attr" json:"probability"`
+	Properties  []Property   `xml:"properties>property" json:"properties"`
+	Image       Image        `xml:"image" json:"-"`
+	Animation   []Frame      `xml:"animation>frame" json:"animation"`
+	ObjectGroup *ObjectGroup `xml:"objectgroup" json:"objectgroup"`
+	Unknown     `json:"-"`
+}
+
+// UnmarshalXML applies Tiled's defaults for the attributes missing from the element.
+func (t *Tile) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type tile Tile
+	v := tile{Probability: 1}
+	if err := d.DecodeElement(&v, &start); err != nil {
+		return err
+	}
+	*t = Tile(v)
+	return nil
+}
+
+// IsAnimated reports whether the tile has animation frames.
+func (t *Tile) IsAnimated() bool {
+	return len(t.Animation) > 0
+}
+
+// Frame is a single step of a tile animation. Duration is in milliseconds.
+type Frame struct {
+	TileID   GID `xml:"tileid,attr" json:"tileid"`
+	Duration int `xml:"duration,attr" json:"duration"`
 }
 
 type TileInfo struct {
@@ -94,19 +267,43 @@ type TileInfo struct {
 }
 
 type Layer struct {
-	ID         int        `xml:"id,attr"`
-	Name       string     `xml:"name,attr"`
-	X          int        `xml:"x,attr"`
-	Y          int        `xml:"y,attr"`
-	Width      int        `xml:"width,attr"`
-	Height     int        `xml:"height,attr"`
-	Opacity    float32    `xml:"opacity,attr"`
-	Visible    bool       `xml:"visible,attr"`
-	OffsetX    int        `xml:"offsetx,attr"`
-	OffsetY    int        `xml:"offsety,attr"`
-	Properties []Property `xml:"properties>property"`
-	Data       Data       `xml:"data"`
-	Tiles      []*TileInfo
+	ID         int         `xml:"id,attr" json:"id"`
+	Name       string      `xml:"name,attr" json:"name"`
+	Class      string      `xml:"class,attr" json:"class"`
+	X          int         `xml:"x,attr" json:"x"`
+	Y          int         `xml:"y,attr" json:"y"`
+	Width      int         `xml:"width,attr" json:"width"`
+	Height     int         `xml:"height,attr" json:"height"`
+	Opacity    float32     `xml:"opacity,attr" json:"opacity"`
+	Visible    bool        `xml:"visible,attr" json:"visible"`
+	Locked     bool        `xml:"locked,attr" json:"locked"`
+	TintColor  string      `xml:"tintcolor,attr" json:"tintcolor"`
+	OffsetX    int         `xml:"offsetx,attr" json:"offsetx"`
+	OffsetY    int         `xml:"offsety,attr" json:"offsety"`
+	ParallaxX  float64     `xml:"parallaxx,attr" json:"parallaxx"`
+	ParallaxY  float64     `xml:"parallaxy,attr" json:"parallaxy"`
+	Properties []Property  `xml:"properties>property" json:"properties"`
+	Data       Data        `xml:"data" json:"-"`
+	Tiles      []*TileInfo `xml:"-" json:"-"`
+
+	// GIDs are the decoded GIDs of the layer, flip flags included, from which Tiles are resolved. Like Tiles, they
+	// are left nil by LoadOptions.SkipTiles and chunks of infinite maps hold their own.
+	GIDs    []GID `xml:"-" json:"-"`
+	Unknown `json:"-"`
+
+	// order is the position of the layer in the decoded document, 0 for layers added afterwards.
+	order int64
+}
+
+// UnmarshalXML applies Tiled's defaults for the attributes missing from the element and records its position.
+func (l *Layer) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type layer Layer
+	v := layer{Opacity: 1, Visible: true, ParallaxX: 1, ParallaxY: 1, order: d.InputOffset()}
+	if err := d.DecodeElement(&v, &start); err != nil {
+		return err
+	}
+	*l = Layer(v)
+	return nil
 }
 
 type Data struct {
@@ -122,81 +319,337 @@ type DataTile struct {
 }
 
 type Chunk struct {
-	X         int        `xml:"x,attr"`
-	Y         int        `xml:"y,attr"`
-	Width     int        `xml:"width,attr"`
-	Height    int        `xml:"height,attr"`
-	DataTiles []DataTile `xml:"tile"`
+	X         int         `xml:"x,attr" json:"x"`
+	Y         int         `xml:"y,attr" json:"y"`
+	Width     int         `xml:"width,attr" json:"width"`
+	Height    int         `xml:"height,attr" json:"height"`
+	RawData   []byte      `xml:",innerxml" json:"-"`
+	DataTiles []DataTile  `xml:"tile" json:"-"`
+	GIDs      []GID       `xml:"-" json:"-"`
+	Tiles     []*TileInfo `xml:"-" json:"-"`
 }
 
 type ObjectGroup struct {
-	Name       string     `xml:"name,attr"`
-	Color      string     `xml:"color,attr"`
-	Opacity    float32    `xml:"opacity,attr"`
-	Visible    bool       `xml:"visible,attr"`
-	Properties []Property `xml:"properties>property"`
-	Objects    []Object   `xml:"object"`
+	ID         int        `xml:"id,attr" json:"id"`
+	Name       string     `xml:"name,attr" json:"name"`
+	Class      string     `xml:"class,attr" json:"class"`
+	Color      string     `xml:"color,attr" json:"color"`
+	Opacity    float32    `xml:"opacity,attr" json:"opacity"`
+	Visible    bool       `xml:"visible,attr" json:"visible"`
+	Locked     bool       `xml:"locked,attr" json:"locked"`
+	TintColor  string     `xml:"tintcolor,attr" json:"tintcolor"`
+	OffsetX    int        `xml:"offsetx,attr" json:"offsetx"`
+	OffsetY    int        `xml:"offsety,attr" json:"offsety"`
+	ParallaxX  float64    `xml:"parallaxx,attr" json:"parallaxx"`
+	ParallaxY  float64    `xml:"parallaxy,attr" json:"parallaxy"`
+	DrawOrder  string     `xml:"draworder,attr" json:"draworder"`
+	Properties []Property `xml:"properties>property" json:"properties"`
+	Objects    []Object   `xml:"object" json:"objects"`
+	Unknown    `json:"-"`
+
+	// order is the position of the layer in the decoded document, 0 for layers added afterwards.
+	order int64
+}
+
+// UnmarshalXML applies Tiled's defaults for the attributes missing from the element and records its position.
+func (og *ObjectGroup) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type objectGroup ObjectGroup
+	v := objectGroup{Opacity: 1, Visible: true, ParallaxX: 1, ParallaxY: 1, DrawOrder: "topdown", order: d.InputOffset()}
+	if err := d.DecodeElement(&v, &start); err != nil {
+		return err
+	}
+	*og = ObjectGroup(v)
+	return nil
 }
 
 type Object struct {
-	Name       string     `xml:"name,attr"`
-	Type       string     `xml:"type,attr"`
-	X          int        `xml:"x,attr"`
-	Y          int        `xml:"y,attr"`
-	Width      int        `xml:"width,attr"`
-	Height     int        `xml:"height,attr"`
-	GID        int        `xml:"gid,attr"`
-	Visible    bool       `xml:"visible,attr"`
-	Properties []Property `xml:"properties>property"`
-	Polygons   []Polygon  `xml:"polygon"`
-	PolyLines  []PolyLine `xml:"polyline"`
+	ID         int        `xml:"id,attr" json:"id"`
+	Name       string     `xml:"name,attr" json:"name"`
+	Type       string     `xml:"type,attr" json:"type"`
+	Class      string     `xml:"class,attr" json:"class"`
+	X          float64    `xml:"x,attr" json:"x"`
+	Y          float64    `xml:"y,attr" json:"y"`
+	Width      float64    `xml:"width,attr" json:"width"`
+	Height     float64    `xml:"height,attr" json:"height"`
+	Rotation   float64    `xml:"rotation,attr" json:"rotation"`
+	GID        int        `xml:"gid,attr" json:"gid"`
+	Visible    bool       `xml:"visible,attr" json:"visible"`
+	Properties []Property `xml:"properties>property" json:"properties"`
+	Ellipse    *struct{}  `xml:"ellipse" json:"-"`
+	Point      *struct{}  `xml:"point" json:"-"`
+	Polygons   []Polygon  `xml:"polygon" json:"-"`
+	PolyLines  []PolyLine `xml:"polyline" json:"-"`
+	Text       *Text      `xml:"text" json:"text"`
+	Template   string     `xml:"template,attr" json:"template"`
+	Unknown    `json:"-"`
+
+	// Tile is the tile of a tile object, resolved from GID.
+	Tile *TileInfo `xml:"-" json:"-"`
+
+	// attrs are the attributes of the element, used to override template defaults.
+	attrs []xml.Attr
+	// instance is the object as declared before its template was merged, encoded in place of the merged view.
+	instance *Object
+}
+
+// UnmarshalXML applies Tiled's defaults for the attributes missing from the element.
+func (o *Object) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type object Object
+	v := object{Visible: true}
+	if err := d.DecodeElement(&v, &start); err != nil {
+		return err
+	}
+	v.attrs = start.Copy().Attr
+	*o = Object(v)
+	o.resolveType()
+	return nil
+}
+
+// resolveType fills the object type from its class. Tiled 1.9 saves the type of objects as their class, earlier
+// and later versions as their type, which takes precedence when both are set.
+func (o *Object) resolveType() {
+	if o.Type == "" {
+		o.Type = o.Class
+	}
+}
+
+// Text holds the content and style of a text object.
+type Text struct {
+	FontFamily string `xml:"fontfamily,attr" json:"fontfamily"`
+	PixelSize  int    `xml:"pixelsize,attr" json:"pixelsize"`
+	Wrap       bool   `xml:"wrap,attr" json:"wrap"`
+	Color      string `xml:"color,attr" json:"color"`
+	Bold       bool   `xml:"bold,attr" json:"bold"`
+	Italic     bool   `xml:"italic,attr" json:"italic"`
+	Underline  bool   `xml:"underline,attr" json:"underline"`
+	Strikeout  bool   `xml:"strikeout,attr" json:"strikeout"`
+	Kerning    bool   `xml:"kerning,attr" json:"kerning"`
+	HAlign     string `xml:"halign,attr" json:"halign"`
+	VAlign     string `xml:"valign,attr" json:"valign"`
+	Value      string `xml:",chardata" json:"text"`
+}
+
+// UnmarshalXML applies Tiled's defaults for the attributes missing from the element.
+func (t *Text) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type text Text
+	v := text{
+		FontFamily: "sans-serif",
+		PixelSize:  16,
+		Kerning:    true,
+		HAlign:     "left",
+		VAlign:     "top",
+	}
+	if err := d.DecodeElement(&v, &start); err != nil {
+		return err
+	}
+	*t = Text(v)
+	return nil
+}
+
+// ObjectKind is the geometry an object represents.
+type ObjectKind int
+
+const (
+	RectangleObject ObjectKind = iota
+	EllipseObject
+	PointObject
+	PolygonObject
+	PolyLineObject
+	TileObject
+	TextObject
+)
+
+// Kind returns the geometry of the object. Ellipses use the object's size as bounding box, points only use X and Y.
+func (o *Object) Kind() ObjectKind {
+	switch {
+	case o.GID != 0:
+		return TileObject
+	case o.Text != nil:
+		return TextObject
+	case o.Ellipse != nil:
+		return EllipseObject
+	case o.Point != nil:
+		return PointObject
+	case len(o.Polygons) > 0:
+		return PolygonObject
+	case len(o.PolyLines) > 0:
+		return PolyLineObject
+	}
+	return RectangleObject
+}
+
+type ImageLayer struct {
+	ID         int        `xml:"id,attr" json:"id"`
+	Name       string     `xml:"name,attr" json:"name"`
+	Class      string     `xml:"class,attr" json:"class"`
+	OffsetX    int        `xml:"offsetx,attr" json:"offsetx"`
+	OffsetY    int        `xml:"offsety,attr" json:"offsety"`
+	ParallaxX  float64    `xml:"parallaxx,attr" json:"parallaxx"`
+	ParallaxY  float64    `xml:"parallaxy,attr" json:"parallaxy"`
+	Opacity    float32    `xml:"opacity,attr" json:"opacity"`
+	Visible    bool       `xml:"visible,attr" json:"visible"`
+	Locked     bool       `xml:"locked,attr" json:"locked"`
+	TintColor  string     `xml:"tintcolor,attr" json:"tintcolor"`
+	RepeatX    bool       `xml:"repeatx,attr" json:"repeatx"`
+	RepeatY    bool       `xml:"repeaty,attr" json:"repeaty"`
+	Properties []Property `xml:"properties>property" json:"properties"`
+	Image      Image      `xml:"image" json:"-"`
+	Unknown    `json:"-"`
+
+	// order is the position of the layer in the decoded document, 0 for layers added afterwards.
+	order int64
+}
+
+// UnmarshalXML applies Tiled's defaults for the attributes missing from the element and records its position.
+func (il *ImageLayer) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type imageLayer ImageLayer
+	v := imageLayer{Opacity: 1, Visible: true, ParallaxX: 1, ParallaxY: 1, order: d.InputOffset()}
+	if err := d.DecodeElement(&v, &start); err != nil {
+		return err
+	}
+	*il = ImageLayer(v)
+	return nil
+}
+
+// Group is a group layer nesting other layers. Offset, opacity and visibility apply to all of its children.
+type Group struct {
+	ID           int           `xml:"id,attr" json:"id"`
+	Name         string        `xml:"name,attr" json:"name"`
+	Class        string        `xml:"class,attr" json:"class"`
+	OffsetX      int           `xml:"offsetx,attr" json:"offsetx"`
+	OffsetY      int           `xml:"offsety,attr" json:"offsety"`
+	ParallaxX    float64       `xml:"parallaxx,attr" json:"parallaxx"`
+	ParallaxY    float64       `xml:"parallaxy,attr" json:"parallaxy"`
+	Opacity      float32       `xml:"opacity,attr" json:"opacity"`
+	Visible      bool          `xml:"visible,attr" json:"visible"`
+	Locked       bool          `xml:"locked,attr" json:"locked"`
+	TintColor    string        `xml:"tintcolor,attr" json:"tintcolor"`
+	Properties   []Property    `xml:"properties>property" json:"properties"`
+	Layers       []Layer       `xml:"layer" json:"-"`
+	ObjectGroups []ObjectGroup `xml:"objectgroup" json:"-"`
+	ImageLayers  []ImageLayer  `xml:"imagelayer" json:"-"`
+	Groups       []Group       `xml:"group" json:"-"`
+	Unknown      `json:"-"`
+
+	// order is the position of the layer in the decoded document, 0 for layers added afterwards.
+	order int64
+}
+
+// UnmarshalXML applies Tiled's defaults for the attributes missing from the element and records its position.
+func (g *Group) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type group Group
+	v := group{Opacity: 1, Visible: true, ParallaxX: 1, ParallaxY: 1, order: d.InputOffset()}
+	if err := d.DecodeElement(&v, &start); err != nil {
+		return err
+	}
+	*g = Group(v)
+	return nil
 }
 
 type Polygon struct {
-	Points string `xml:"points,attr"`
+	Points string `xml:"points,attr" json:"points"`
 }
 
 type PolyLine struct {
-	Points string `xml:"points,attr"`
+	Points string `xml:"points,attr" json:"points"`
 }
 
-func (l *Layer) decodeXML() ([]GID, error) {
-	gids := make([]GID, l.Width*l.Height)
+func (d *Data) decodeXML(dataTiles []DataTile, size int) ([]GID, error) {
+	if len(dataTiles) != size {
+		return nil, invalidSizeError(size, len(dataTiles))
+	}
+	gids := make([]GID, size)
 	for i := 0; i < len(gids); i++ {
-		gids[i] = l.Data.DataTiles[i].GID
+		gids[i] = dataTiles[i].GID
 	}
 	return gids, nil
 }
 
-func (l *Layer) decodeBase64() ([]GID, error) {
-	sanitized := bytes.TrimSpace(l.Data.RawData)
-	decoder := base64.NewDecoder(base64.StdEncoding, bytes.NewReader(sanitized))
+// decompress decodes base64 data and decompresses it according to the data compression. Whitespace within the data
+// and missing padding are tolerated.
+func (d *Data) decompress(rawData []byte) ([]byte, error) {
+	sanitized := bytes.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, rawData)
+	sanitized = bytes.TrimRight(sanitized, "=")
+	decoder := base64.NewDecoder(base64.RawStdEncoding, bytes.NewReader(sanitized))
+	return decompress(d.Compression, decoder)
+}
 
+func decompress(compression string, r io.Reader) ([]byte, error) {
 	var reader io.Reader
 	var err error
-	switch l.Data.Compression {
+	switch compression {
 	case "":
-		reader = decoder
+		reader = r
 	case "gzip":
-		reader, err = gzip.NewReader(decoder)
+		reader, err = gzip.NewReader(r)
 		if err != nil {
 			return nil, err
 		}
 	case "zlib":
-		reader, err = zlib.NewReader(decoder)
+		reader, err = zlib.NewReader(r)
 		if err != nil {
 			return nil, err
 		}
+	case "zstd":
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("zstd: %w", err)
+		}
+		defer zr.Close()
+		reader = zr
 	default:
-		return nil, fmt.Errorf("unsupported compression: %s", l.Data.Compression)
+		return nil, fmt.Errorf("unsupported compression: %s", compression)
 	}
 
 	data, err := ioutil.ReadAll(reader)
 	if err != nil {
+		if compression == "zstd" {
+			return nil, fmt.Errorf("zstd: %w", err)
+		}
 		return nil, err
 	}
+	return data, nil
+}
 
-	gids := make([]GID, l.Width*l.Height)
+// sniffCompression returns the compression whose header starts the data, if any.
+func sniffCompression(data []byte) string {
+	switch {
+	case len(data) >= 3 && data[0] == 0x1f && data[1] == 0x8b && data[2] == 8:
+		return "gzip"
+	case len(data) >= 2 && data[0]&0x0f == 8 && (uint16(data[0])<<8|uint16(data[1]))%31 == 0:
+		return "zlib"
+	}
+	return ""
+}
+
+// decodeBase64 decodes base64 data. Data labelled as uncompressed but not matching the layer size is sniffed for a
+// gzip or zlib header, in which case the detected compression is stored in d.Compression.
+func (d *Data) decodeBase64(rawData []byte, size int) ([]GID, error) {
+	data, err := d.decompress(rawData)
+	if err != nil {
+		return nil, err
+	}
+	if d.Compression == "" && len(data) != size*4 {
+		if compression := sniffCompression(data); compression != "" {
+			if data, err = decompress(compression, bytes.NewReader(data)); err != nil {
+				return nil, err
+			}
+			d.Compression = compression
+		}
+	}
+
+	if len(data)%4 != 0 {
+		return nil, fmt.Errorf("invalid layer data size: %d bytes is not a multiple of 4", len(data))
+	}
+	if len(data) != size*4 {
+		return nil, invalidSizeError(size, len(data)/4)
+	}
+	gids := make([]GID, size)
 	for i := 0; i < len(data)/4; i++ {
 		gids[i] = GID(data[i*4]) +
 			GID(data[i*4+1])<<8 +
@@ -206,19 +659,25 @@ func (l *Layer) decodeBase64() ([]GID, error) {
 	return gids, nil
 }
 
-func (l *Layer) decodeCSV() ([]GID, error) {
+func (d *Data) decodeCSV(rawData []byte, size int) ([]GID, error) {
 	sanitized := strings.Map(func(r rune) rune {
 		if (r >= '0' && r <= '9') || r == ',' {
 			return r
 		}
 		return -1
-	}, string(l.Data.RawData))
+	}, string(rawData))
 
-	tokens := strings.Split(sanitized, ",")
+	// Skip the empty tokens left by trailing commas
+	tokens := strings.FieldsFunc(sanitized, func(r rune) bool {
+		return r == ','
+	})
+	if len(tokens) != size {
+		return nil, invalidSizeError(size, len(tokens))
+	}
 
-	gids := make([]GID, l.Width*l.Height)
+	gids := make([]GID, size)
 	for i, token := range tokens {
-		gid, err := strconv.Atoi(token)
+		gid, err := strconv.ParseUint(token, 10, 32)
 		if err != nil {
 			return nil, err
 		}
@@ -228,46 +687,187 @@ func (l *Layer) decodeCSV() ([]GID, error) {
 	return gids, nil
 }
 
-func (l *Layer) decode() ([]GID, error) {
-	switch l.Data.Encoding {
+func invalidSizeError(expected, actual int) error {
+	return fmt.Errorf("invalid layer data size: expected %d tiles, got %d", expected, actual)
+}
+
+// decode decodes the GIDs of the data according to its encoding. An empty data body, as Tiled may leave for layers
+// without tiles, decodes to size empty GIDs.
+func (d *Data) decode(rawData []byte, dataTiles []DataTile, size int) ([]GID, error) {
+	if len(dataTiles) == 0 && len(bytes.TrimSpace(rawData)) == 0 {
+		return make([]GID, size), nil
+	}
+	if err := d.checkEncoding(rawData, dataTiles); err != nil {
+		return nil, err
+	}
+	switch d.Encoding {
 	case "":
-		return l.decodeXML()
+		return d.decodeXML(dataTiles, size)
 	case "base64":
-		return l.decodeBase64()
+		return d.decodeBase64(rawData, size)
 	case "csv":
-		return l.decodeCSV()
+		return d.decodeCSV(rawData, size)
+	}
+	return nil, fmt.Errorf("unsupported encoding: %s", d.Encoding)
+}
+
+// checkEncoding reports data whose content does not match its encoding, as left by hand edits or other tools:
+// <tile> elements with an encoding, or text without one.
+func (d *Data) checkEncoding(rawData []byte, dataTiles []DataTile) error {
+	switch {
+	case d.Encoding != "" && len(dataTiles) > 0:
+		return fmt.Errorf("%s encoded data holds <tile> elements, which are only valid without encoding", d.Encoding)
+	case d.Encoding == "" && len(dataTiles) == 0 && !bytes.HasPrefix(bytes.TrimSpace(rawData), []byte("<")):
+		return errors.New("data without encoding holds text, csv or base64 encoding expected")
+	}
+	return nil
+}
+
+func (l *Layer) decode() ([]GID, error) {
+	return l.Data.decode(l.Data.RawData, l.Data.DataTiles, l.Width*l.Height)
+}
+
+func (c *Chunk) decode(data *Data) ([]GID, error) {
+	return data.decode(c.RawData, c.DataTiles, c.Width*c.Height)
+}
+
+// ChunkTiles returns the decoded tiles of an infinite map layer, keyed by chunk origin.
+func (l *Layer) ChunkTiles() map[image.Point][]*TileInfo {
+	if len(l.Data.Chunk) == 0 {
+		return nil
+	}
+	chunks := make(map[image.Point][]*TileInfo, len(l.Data.Chunk))
+	for _, chunk := range l.Data.Chunk {
+		chunks[image.Pt(chunk.X, chunk.Y)] = chunk.Tiles
+	}
+	return chunks
+}
+
+func (i *Image) decode(ld *loader, baseDir string) error {
+	if i == nil || ld.SkipImages {
+		return nil
+	}
+	if err := i.load(ld, baseDir); err != nil {
+		return err
+	}
+	if ld.ApplyTransparency && i.Image != nil && i.Trans != "" {
+		img, err := applyTransparency(i.Image, i.Trans)
+		if err != nil {
+			return err
+		}
+		i.Image = img
 	}
-	return nil, fmt.Errorf("unsupported encoding: %s", l.Data.Encoding)
+	return nil
 }
 
-func (i *Image) decode(baseDir string) error {
-	file, err := os.Open(filepath.Join(baseDir, i.Source))
+func (i *Image) load(ld *loader, baseDir string) error {
+	if i.Data != nil {
+		return i.decodeData(ld)
+	}
+	if i.Source == "" {
+		return nil
+	}
+	source := path.Join(baseDir, i.Source)
+	if err := ld.checkContext(source); err != nil {
+		return err
+	}
+	if ld.ImageLoader != nil {
+		img, err := ld.ImageLoader(source)
+		if err != nil {
+			return err
+		}
+		i.Image = img
+		return nil
+	}
+	if ld.fsys == nil {
+		return nil
+	}
+
+	file, err := ld.fsys.Open(source)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
-	i.Image, _, err = image.Decode(file)
+	i.Image, err = ld.decodeImage(file)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (ts *TileSet) decode(baseDir string) error {
+// decodeData decodes the image embedded in the map.
+func (i *Image) decodeData(ld *loader) error {
+	if i.Data.Encoding != "base64" {
+		return fmt.Errorf("unsupported image data encoding: %s", i.Data.Encoding)
+	}
+	data, err := i.Data.decompress(i.Data.RawData)
+	if err != nil {
+		return err
+	}
+	i.Image, err = ld.decodeImage(bytes.NewReader(data))
+	return err
+}
+
+// decode loads the external tileset, if any, and its image. Images are resolved relative to the tileset file.
+func (ts *TileSet) decode(ld *loader, baseDir string) error {
 	if ts.Source == "" {
+		return ts.decodeImages(ld, baseDir)
+	}
+	if ld.fsys == nil || ld.SkipExternalTileSets {
 		return nil
 	}
-	file, err := os.Open(filepath.Join(baseDir, ts.Source))
+
+	source := path.Join(baseDir, ts.Source)
+	if ld.TileSetCache == nil {
+		return ts.decodeSource(ld, source)
+	}
+	cached, err := ld.TileSetCache.load(source, func(cached *TileSet) error {
+		return cached.decodeSource(ld, source)
+	})
+	if err != nil {
+		return err
+	}
+	firstGID, src := ts.FirstGID, ts.Source
+	*ts = cached
+	ts.FirstGID, ts.Source = firstGID, src
+	return nil
+}
+
+func (ts *TileSet) decodeSource(ld *loader, source string) error {
+	if err := ld.checkContext(source); err != nil {
+		return err
+	}
+	file, err := ld.fsys.Open(source)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
-	decoder := xml.NewDecoder(file)
-	if err := decoder.Decode(ts); err != nil {
+	if isJSON(source) {
+		err = json.NewDecoder(file).Decode(ts)
+	} else {
+		err = ld.newXMLDecoder(file).Decode(ts)
+	}
+	if err != nil {
+		return err
+	}
+	return ts.decodeImages(ld, path.Dir(source))
+}
+
+func (ts *TileSet) decodeImages(ld *loader, dir string) error {
+	ts.dir = dir
+	if err := ts.Image.decode(ld, dir); err != nil {
 		return err
 	}
+	if ts.Columns == 0 {
+		ts.Columns = ts.imageColumns()
+	}
+	for i := range ts.Tiles {
+		if err := ts.Tiles[i].Image.decode(ld, dir); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -276,81 +876,574 @@ func (m *Map) decodeGID(gid GID) (*TileInfo, error) {
 		return NilTile, nil
 	}
 
-	clearGID := gid &^ (horizontalFlip | verticalFlip | diagonalFlip)
-	for i := len(m.TileSets) - 1; i >= 0; i-- {
-		if m.TileSets[i].FirstGID <= clearGID {
-			return &TileInfo{
-				ID:             clearGID - m.TileSets[i].FirstGID,
-				TileSet:        &m.TileSets[i],
-				HorizontalFlip: gid&horizontalFlip != 0,
-				VerticalFlip:   gid&verticalFlip != 0,
-				DiagonalFlip:   gid&diagonalFlip != 0,
-				Nil:            gid == 0,
-			}, nil
+	clearGID := gid.Clear()
+	i := m.tileSetIndex(clearGID)
+	if i < 0 {
+		return nil, &InvalidGIDError{GID: gid}
+	}
+	horizontal, vertical, diagonal := gid.Flags()
+	return &TileInfo{
+		ID:             clearGID - m.TileSets[i].FirstGID,
+		TileSet:        m.TileSets[i],
+		HorizontalFlip: horizontal,
+		VerticalFlip:   vertical,
+		DiagonalFlip:   diagonal,
+		Nil:            gid == 0,
+	}, nil
+}
+
+// tileSetIndex returns the index of the tileset containing the GID, without flags, or -1 if there is none. The
+// tilesets are binary searched once indexed, and scanned otherwise.
+func (m *Map) tileSetIndex(gid GID) int {
+	if len(m.tileSetOrder) != len(m.TileSets) {
+		for i := len(m.TileSets) - 1; i >= 0; i-- {
+			if m.TileSets[i].FirstGID <= gid {
+				return i
+			}
+		}
+		return -1
+	}
+
+	n := sort.Search(len(m.tileSetOrder), func(k int) bool {
+		return m.TileSets[m.tileSetOrder[k]].FirstGID > gid
+	})
+	if n == 0 {
+		return -1
+	}
+	return m.tileSetOrder[n-1]
+}
+
+// indexTileSets sorts the tilesets by first GID for tileSetIndex.
+func (m *Map) indexTileSets() {
+	m.tileSetOrder = make([]int, len(m.TileSets))
+	for i := range m.tileSetOrder {
+		m.tileSetOrder[i] = i
+	}
+	sort.SliceStable(m.tileSetOrder, func(a, b int) bool {
+		return m.TileSets[m.tileSetOrder[a]].FirstGID < m.TileSets[m.tileSetOrder[b]].FirstGID
+	})
+}
+
+// InvalidGIDError is returned when a layer or a tile object references a GID not covered by any tileset of the
+// map. For objects, Layer is the object group name and Index the position of the object in the group. Both are
+// left empty for GIDs looked up with Map.TileByGID.
+type InvalidGIDError struct {
+	GID   GID
+	Layer string
+	// Index is the position of the tile in the layer data, or in the chunk data for infinite maps.
+	Index int
+}
+
+func (e *InvalidGIDError) Error() string {
+	if e.Layer == "" {
+		return fmt.Sprintf("invalid tile GID: %d", e.GID)
+	}
+	return fmt.Sprintf("invalid tile GID: %d in layer %q at index %d", e.GID, e.Layer, e.Index)
+}
+
+func (m *Map) decodeGIDs(ld *loader, layer string, gids []GID) ([]*TileInfo, error) {
+	tiles := make([]*TileInfo, len(gids))
+	for i := range tiles {
+		tile, err := m.resolveGID(ld, layer, i, gids[i])
+		if err != nil {
+			return nil, err
+		}
+		tiles[i] = tile
+	}
+	return tiles, nil
+}
+
+// resolveGID decodes the GID found at the given index of a layer, replacing it with NilTile when invalid GIDs are
+// ignored.
+func (m *Map) resolveGID(ld *loader, layer string, index int, gid GID) (*TileInfo, error) {
+	tile, err := m.decodeGID(gid)
+	if err != nil {
+		invalid, ok := err.(*InvalidGIDError)
+		if !ok {
+			return nil, err
+		}
+		invalid.Layer, invalid.Index = layer, index
+		if !ld.IgnoreInvalidGID {
+			return nil, err
+		}
+		m.InvalidGIDs = append(m.InvalidGIDs, invalid)
+		tile = NilTile
+	}
+	return tile, nil
+}
+
+// decodeObjectTiles resolves the tile of each tile object.
+func (m *Map) decodeObjectTiles(ld *loader) error {
+	return m.walkObjectGroups(m.ObjectGroups, m.Groups, func(og *ObjectGroup) error {
+		for i := range og.Objects {
+			object := &og.Objects[i]
+			if object.GID == 0 {
+				continue
+			}
+			var err error
+			if object.Tile, err = m.resolveGID(ld, og.Name, i, GID(object.GID)); err != nil {
+				return err
+			}
 		}
+		return nil
+	})
+}
+
+func (m *Map) decode(ld *loader, baseDir string) error {
+	m.fsys, m.baseDir, m.options = ld.fsys, baseDir, ld.LoadOptions
+	m.indexTileSets()
+	if err := m.decodeLayers(ld, m.Layers, m.Groups); err != nil {
+		return err
 	}
 
-	return nil, fmt.Errorf("invalid tile GID: %d\n", gid)
+	var jobs []func() error
+	for _, ts := range m.TileSets {
+		jobs = append(jobs, func() error {
+			return ts.decode(ld, baseDir)
+		})
+	}
+	m.walkImageLayers(m.ImageLayers, m.Groups, func(il *ImageLayer) {
+		jobs = append(jobs, func() error {
+			return il.Image.decode(ld, baseDir)
+		})
+	})
+	if err := runParallel(jobs); err != nil {
+		return err
+	}
+	if err := m.decodeTemplates(ld, baseDir); err != nil {
+		return err
+	}
+	return m.decodeObjectTiles(ld)
 }
 
-func (m *Map) decode(baseDir string) error {
-	for i := range m.TileSets {
-		if err := m.TileSets[i].decode(baseDir); err != nil {
+func (m *Map) walkImageLayers(imageLayers []ImageLayer, groups []Group, fn func(il *ImageLayer)) {
+	for i := range imageLayers {
+		fn(&imageLayers[i])
+	}
+	for i := range groups {
+		m.walkImageLayers(groups[i].ImageLayers, groups[i].Groups, fn)
+	}
+}
+
+// runParallel runs the jobs on at most GOMAXPROCS goroutines and returns the first error encountered. Remaining
+// jobs are not started once a job fails.
+func runParallel(jobs []func() error) error {
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		failed   int32
+		firstErr error
+	)
+	queue := make(chan func() error)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				if err := job(); err != nil {
+					once.Do(func() {
+						firstErr = err
+						atomic.StoreInt32(&failed, 1)
+					})
+				}
+			}
+		}()
+	}
+	for _, job := range jobs {
+		if atomic.LoadInt32(&failed) != 0 {
+			break
+		}
+		queue <- job
+	}
+	close(queue)
+	wg.Wait()
+	return firstErr
+}
+
+func (m *Map) warnf(format string, args ...any) {
+	m.Warnings = append(m.Warnings, fmt.Sprintf(format, args...))
+}
+
+func (m *Map) decodeLayers(ld *loader, layers []Layer, groups []Group) error {
+	if ld.SkipTiles {
+		return nil
+	}
+	for i := range layers {
+		if err := m.decodeLayer(ld, &layers[i]); err != nil {
 			return err
 		}
-		if err := m.TileSets[i].Image.decode(baseDir); err != nil {
+	}
+	for i := range groups {
+		if err := m.decodeLayers(ld, groups[i].Layers, groups[i].Groups); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-// Load
+func (m *Map) decodeLayer(ld *loader, layer *Layer) error {
+	if len(layer.Data.Chunk) > 0 {
+		for j := range layer.Data.Chunk {
+			chunk := &layer.Data.Chunk[j]
+			gids, err := m.decodeData(layer, chunk.RawData, chunk.DataTiles, chunk.Width*chunk.Height)
+			if err != nil {
+				return err
+			}
+			chunk.GIDs = gids
+			if chunk.Tiles, err = m.decodeGIDs(ld, layer.Name, gids); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	gids, err := m.decodeData(layer, layer.Data.RawData, layer.Data.DataTiles, layer.Width*layer.Height)
+	if err != nil {
+		return err
+	}
+	layer.GIDs = gids
+	layer.Tiles, err = m.decodeGIDs(ld, layer.Name, gids)
+	return err
+}
+
+// decodeData decodes the GIDs of the layer data or of one of its chunks, warning when the compression had to be
+// sniffed.
+func (m *Map) decodeData(layer *Layer, rawData []byte, dataTiles []DataTile, size int) ([]GID, error) {
+	compression := layer.Data.Compression
+	gids, err := layer.Data.decode(rawData, dataTiles, size)
+	if err != nil {
+		return nil, fmt.Errorf("layer %q: %w", layer.Name, err)
+	}
+	if layer.Data.Compression != compression {
+		m.warnf("layer %q: %s compressed data without compression attribute", layer.Name, layer.Data.Compression)
+	}
+	return gids, nil
+}
+
+// LoadOptions configures how maps are loaded. The zero value loads maps the same way as Load, LoadFS and Decode.
+type LoadOptions struct {
+	// ImageLoader, when set, loads images instead of the file system. It receives the image source resolved
+	// against the directory of the map or tileset referencing it. With Decode, the source is left as is.
+	// Images are loaded concurrently, so ImageLoader must be safe for concurrent use.
+	ImageLoader func(source string) (image.Image, error)
+
+	// ImageDecoder, when set, decodes the image files read from the file system and the images embedded in the
+	// map instead of image.Decode, which only knows the GIF, JPEG and PNG formats unless others are registered.
+	ImageDecoder func(r io.Reader) (image.Image, error)
+
+	// SkipImages leaves Image.Image nil. Image sources and sizes are still available.
+	SkipImages bool
+
+	// ApplyTransparency replaces the pixels matching the transparent color of an image, Image.Trans, with fully
+	// transparent ones. The image is copied into an *image.NRGBA.
+	ApplyTransparency bool
+
+	// TileSetCache, when set, decodes each external tileset once and shares it between the maps loaded with the
+	// cache. A cache should only be shared between loads using the same options and file system.
+	TileSetCache *TileSetCache
+
+	// SkipExternalTileSets leaves external tilesets as references holding their first GID and source, without
+	// reading their files. Tiles then resolve to the referencing tileset, their ID being relative to its first GID.
+	SkipExternalTileSets bool
+
+	// SkipTiles leaves Layer.Tiles and Chunk.Tiles nil. Tiles can then be visited with Map.TilesSeq, which resolves
+	// them on demand and keeps memory low on large maps.
+	SkipTiles bool
+
+	// IgnoreInvalidGID replaces GIDs not covered by any tileset with NilTile instead of failing. The replaced GIDs
+	// are listed in Map.InvalidGIDs and kept in Layer.GIDs, from which Map.Encode writes them back.
+	IgnoreInvalidGID bool
+
+	// CharsetReader, when set, converts TMX, TSX and TX files declaring a non UTF-8 encoding in their XML prolog,
+	// such as ISO-8859-1, to UTF-8. It has the signature of xml.Decoder.CharsetReader, which
+	// golang.org/x/net/html/charset.NewReaderLabel satisfies.
+	CharsetReader func(charset string, input io.Reader) (io.Reader, error)
+}
+
+// loader carries the load options, the file system sources are resolved from and the context of the load.
+type loader struct {
+	LoadOptions
+	fsys fs.FS
+	ctx  context.Context
+}
+
+// decodeImage decodes an image with the ImageDecoder, or image.Decode when none is set.
+func (ld *loader) decodeImage(r io.Reader) (image.Image, error) {
+	if ld.ImageDecoder != nil {
+		return ld.ImageDecoder(r)
+	}
+	img, _, err := image.Decode(r)
+	return img, err
+}
+
+// checkContext returns the error of a done context, wrapped with the asset about to be loaded.
+func (ld *loader) checkContext(asset string) error {
+	if err := ld.ctx.Err(); err != nil {
+		return fmt.Errorf("loading %s: %w", asset, err)
+	}
+	return nil
+}
+
+// Load loads a map, detecting whether it uses the TMX or the JSON format from its content.
 func Load(name string) (*Map, error) {
-	file, err := os.Open(name)
+	return LoadOptions{}.Load(name)
+}
+
+// LoadFS loads a map from the given file system. Tilesets and images are resolved relative to the map directory.
+func LoadFS(fsys fs.FS, name string) (*Map, error) {
+	return LoadOptions{}.LoadFS(fsys, name)
+}
+
+// Decode decodes a map without resolving external tilesets and images.
+func Decode(tileMap io.Reader) (*Map, error) {
+	return LoadOptions{}.Decode(tileMap)
+}
+
+// Resolve runs the steps following the unmarshalling of a map, for maps unmarshalled by the caller, for instance
+// within a struct adding custom fields. External tilesets, templates and images are loaded relative to baseDir, or
+// left unresolved when it is empty as with Decode, and the layer and object GIDs are resolved to tiles. It must be
+// called once per map.
+func (m *Map) Resolve(baseDir string) error {
+	return LoadOptions{}.Resolve(m, baseDir)
+}
+
+// DecodeDir decodes a map, resolving external tilesets and images relative to baseDir.
+func DecodeDir(tileMap io.Reader, baseDir string) (*Map, error) {
+	return LoadOptions{}.DecodeDir(tileMap, baseDir)
+}
+
+// LoadBytes decodes a map held in memory, resolving external tilesets and images relative to baseDir. An empty
+// baseDir leaves them unresolved, as Decode does.
+func LoadBytes(data []byte, baseDir string) (*Map, error) {
+	return LoadOptions{}.LoadBytes(data, baseDir)
+}
+
+// LoadContext loads a map like Load, aborting once ctx is done.
+func LoadContext(ctx context.Context, name string) (*Map, error) {
+	return LoadOptions{}.LoadContext(ctx, name)
+}
+
+// LoadTileSet loads a standalone tileset (.tsx or .tsj) and its images.
+func LoadTileSet(name string) (*TileSet, error) {
+	return LoadOptions{}.LoadTileSet(name)
+}
+
+// LoadTMX loads a map in the TMX format.
+func LoadTMX(name string) (*Map, error) {
+	return LoadOptions{}.LoadTMX(name)
+}
+
+// LoadJSON loads a map in the JSON map format (.tmj or .json). External tilesets may use either format.
+func LoadJSON(name string) (*Map, error) {
+	return LoadOptions{}.LoadJSON(name)
+}
+
+// DecodeJSON decodes a map in the JSON map format without resolving external tilesets and images.
+func DecodeJSON(tileMap io.Reader) (*Map, error) {
+	return LoadOptions{}.DecodeJSON(tileMap)
+}
+
+// rootFS returns the file system of the volume containing name, and the path of name within it.
+func rootFS(name string) (fs.FS, string, error) {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return nil, "", err
+	}
+	root := filepath.VolumeName(abs) + string(filepath.Separator)
+	rel, err := filepath.Rel(root, abs)
+	if err != nil {
+		return nil, "", err
+	}
+	return os.DirFS(root), filepath.ToSlash(rel), nil
+}
+
+// Load loads a map from the file system using the options.
+func (o LoadOptions) Load(name string) (*Map, error) {
+	fsys, name, err := rootFS(name)
+	if err != nil {
+		return nil, err
+	}
+	return o.LoadFS(fsys, name)
+}
+
+// LoadFS loads a map from the given file system using the options. Both the TMX and JSON formats are supported.
+func (o LoadOptions) LoadFS(fsys fs.FS, name string) (*Map, error) {
+	return o.loadFS(context.Background(), fsys, name, LoadOptions.decodeAny)
+}
+
+// LoadContext loads a map from the file system using the options. The load is aborted between tileset and image
+// decodes once ctx is done.
+func (o LoadOptions) LoadContext(ctx context.Context, name string) (*Map, error) {
+	fsys, name, err := rootFS(name)
+	if err != nil {
+		return nil, err
+	}
+	return o.loadFS(ctx, fsys, name, LoadOptions.decodeAny)
+}
+
+// LoadTileSet loads a standalone tileset (.tsx or .tsj) and its images using the options. SkipExternalTileSets
+// does not apply to the tileset being loaded.
+func (o LoadOptions) LoadTileSet(name string) (*TileSet, error) {
+	fsys, name, err := rootFS(name)
+	if err != nil {
+		return nil, err
+	}
+
+	o.SkipExternalTileSets = false
+
+	ts := &TileSet{Source: path.Base(name)}
+	if err := ts.decode(&loader{LoadOptions: o, fsys: fsys, ctx: context.Background()}, path.Dir(name)); err != nil {
+		return nil, err
+	}
+	ts.Source = ""
+	return ts, nil
+}
+
+// LoadTMX loads a map in the TMX format from the file system using the options.
+func (o LoadOptions) LoadTMX(name string) (*Map, error) {
+	fsys, name, err := rootFS(name)
+	if err != nil {
+		return nil, err
+	}
+	return o.loadFS(context.Background(), fsys, name, LoadOptions.decodeXML)
+}
+
+// LoadJSON loads a map in the JSON map format from the file system using the options.
+func (o LoadOptions) LoadJSON(name string) (*Map, error) {
+	fsys, name, err := rootFS(name)
+	if err != nil {
+		return nil, err
+	}
+	return o.loadFS(context.Background(), fsys, name, LoadOptions.decodeJSON)
+}
+
+func (o LoadOptions) loadFS(ctx context.Context, fsys fs.FS, name string,
+	decodeFormat func(LoadOptions, io.Reader) (*Map, error)) (*Map, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("loading %s: %w", name, err)
+	}
+	file, err := fsys.Open(name)
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
 
-	tmx, err := Decode(file)
+	tmx, err := decodeFormat(o, file)
 	if err != nil {
 		return nil, err
 	}
 
-	baseDir, err := filepath.Abs(filepath.Dir(name))
+	if err := tmx.decode(&loader{LoadOptions: o, fsys: fsys, ctx: ctx}, path.Dir(name)); err != nil {
+		return nil, err
+	}
+	return tmx, nil
+}
+
+// Decode decodes a map in either format using the options. External tilesets are not resolved, images are only
+// loaded when an ImageLoader is set.
+func (o LoadOptions) Decode(tileMap io.Reader) (*Map, error) {
+	return o.decode(tileMap, LoadOptions.decodeAny)
+}
+
+// DecodeJSON decodes a map in the JSON map format using the options, the same way as Decode.
+func (o LoadOptions) DecodeJSON(tileMap io.Reader) (*Map, error) {
+	return o.decode(tileMap, LoadOptions.decodeJSON)
+}
+
+func (o LoadOptions) decode(tileMap io.Reader, decodeFormat func(LoadOptions, io.Reader) (*Map, error)) (*Map, error) {
+	tmx, err := decodeFormat(o, tileMap)
 	if err != nil {
 		return nil, err
 	}
-	if err := tmx.decode(baseDir); err != nil {
+
+	if err := o.Resolve(tmx, ""); err != nil {
 		return nil, err
 	}
 	return tmx, nil
 }
 
-// Load
-func Decode(tileMap io.Reader) (*Map, error) {
-	tmx := &Map{}
-	decoder := xml.NewDecoder(tileMap)
-	if err := decoder.Decode(tmx); err != nil {
+// LoadBytes decodes a map held in memory using the options, resolving external tilesets and images relative to
+// baseDir. An empty baseDir leaves them unresolved, as Decode does.
+func (o LoadOptions) LoadBytes(data []byte, baseDir string) (*Map, error) {
+	if baseDir == "" {
+		return o.Decode(bytes.NewReader(data))
+	}
+	return o.DecodeDir(bytes.NewReader(data), baseDir)
+}
+
+// DecodeDir decodes a map using the options, resolving external tilesets and images relative to baseDir.
+func (o LoadOptions) DecodeDir(tileMap io.Reader, baseDir string) (*Map, error) {
+	tmx, err := o.decodeAny(tileMap)
+	if err != nil {
 		return nil, err
 	}
 
-	for i := range tmx.Layers {
-		layer := &tmx.Layers[i]
-		gids, err := layer.decode()
+	if err := o.Resolve(tmx, baseDir); err != nil {
+		return nil, err
+	}
+	return tmx, nil
+}
+
+// Resolve runs the steps following the unmarshalling of a map using the options, the same way as Map.Resolve.
+func (o LoadOptions) Resolve(m *Map, baseDir string) error {
+	ld := &loader{LoadOptions: o, ctx: context.Background()}
+	var dir string
+	if baseDir != "" {
+		fsys, rel, err := rootFS(baseDir)
 		if err != nil {
-			return nil, err
+			return err
 		}
+		ld.fsys, dir = fsys, rel
+	}
+	return m.decode(ld, dir)
+}
 
-		layer.Tiles = make([]*TileInfo, len(gids))
-		for j := 0; j < len(layer.Tiles); j++ {
-			layer.Tiles[j], err = tmx.decodeGID(gids[j])
-			if err != nil {
-				return nil, err
-			}
+// decodeAny decodes a map in the JSON format if its first non-whitespace byte opens an object, in the TMX format
+// otherwise.
+func (o LoadOptions) decodeAny(tileMap io.Reader) (*Map, error) {
+	r := bufio.NewReader(tileMap)
+	for {
+		b, err := r.Peek(1)
+		if err != nil {
+			return o.decodeXML(r)
 		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			r.Discard(1)
+			continue
+		case '{':
+			return o.decodeJSON(r)
+		}
+		return o.decodeXML(r)
+	}
+}
+
+func (o LoadOptions) decodeXML(tileMap io.Reader) (*Map, error) {
+	tmx := &Map{}
+	decoder := o.newXMLDecoder(tileMap)
+	if err := decoder.Decode(tmx); err != nil {
+		return nil, err
 	}
+	return tmx, nil
+}
 
+func (o LoadOptions) decodeJSON(tileMap io.Reader) (*Map, error) {
+	tmx := &Map{}
+	decoder := json.NewDecoder(tileMap)
+	if err := decoder.Decode(tmx); err != nil {
+		return nil, err
+	}
 	return tmx, nil
 }
+
+// newXMLDecoder returns a decoder for r converting non UTF-8 documents with the CharsetReader of the options.
+func (o LoadOptions) newXMLDecoder(r io.Reader) *xml.Decoder {
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = o.CharsetReader
+	return decoder
+}
@@ -1,8 +1,21 @@
 package tmxmap
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"io"
 	"os"
+	"runtime"
+	"slices"
+	"strings"
 	"testing"
+	"testing/fstest"
 )
 
 func TestExternal(t *testing.T) {
@@ -59,3 +72,842 @@ func TestDecodeEmbedded(t *testing.T) {
 		t.Errorf("tileset Image.Image should be null")
 	}
 }
+
+func TestZstd(t *testing.T) {
+	zlib, err := Load("assets/external/track1_bg.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	zstd, err := Load("assets/external/track1_bg_zstd.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zstd.Layers[0].Tiles) != len(zlib.Layers[0].Tiles) {
+		t.Fatalf("expected %d tiles, got %d", len(zlib.Layers[0].Tiles), len(zstd.Layers[0].Tiles))
+	}
+	for i, tile := range zstd.Layers[0].Tiles {
+		if tile.ID != zlib.Layers[0].Tiles[i].ID {
+			t.Errorf("tile %d: expected ID %d, got %d", i, zlib.Layers[0].Tiles[i].ID, tile.ID)
+		}
+	}
+}
+
+func TestCorruptZstd(t *testing.T) {
+	zstd, err := Load("assets/external/track1_bg_zstd.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := zstd.Layers[0].EncodeData("base64", "zstd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed, err := base64.StdEncoding.DecodeString(string(data.RawData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, payload := range map[string][]byte{
+		"truncated": compressed[:len(compressed)/2],
+		"garbage":   append(slices.Clone(compressed[:4]), bytes.Repeat([]byte{0xff}, 32)...),
+	} {
+		encoded := base64.StdEncoding.EncodeToString(payload)
+		_, err := Decode(strings.NewReader(layerMap(32, 6, `<data encoding="base64" compression="zstd">`+encoded+`</data>`)))
+		if err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+		var zstdErr error
+		for e := err; e != nil; e = errors.Unwrap(e) {
+			if strings.HasPrefix(e.Error(), "zstd: ") {
+				zstdErr = e
+				break
+			}
+		}
+		if zstdErr == nil || errors.Unwrap(zstdErr) == nil {
+			t.Errorf("%s: expected a wrapped zstd error, got %v", name, err)
+		}
+	}
+}
+
+func TestDecodeInfiniteCSV(t *testing.T) {
+	infinite, err := os.Open("assets/infinite/infinite_csv.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer infinite.Close()
+
+	tmx, err := Decode(infinite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tmx.Infinite {
+		t.Errorf("map should be infinite")
+	}
+	chunks := tmx.Layers[0].ChunkTiles()
+	if len(chunks) != 2 {
+		t.Fatalf("expected 2 chunks, got %d", len(chunks))
+	}
+	left, right := chunks[image.Pt(-4, 0)], chunks[image.Pt(0, 0)]
+	if len(left) != 16 || len(right) != 16 {
+		t.Fatalf("expected 16 tiles per chunk, got %d and %d", len(left), len(right))
+	}
+	if left[0].ID != 0 || right[0].ID != 16 {
+		t.Errorf("unexpected first tile IDs: %d and %d", left[0].ID, right[0].ID)
+	}
+	if !right[15].Nil {
+		t.Errorf("last tile of the right chunk should be nil")
+	}
+}
+
+func TestDecodeInfiniteBase64(t *testing.T) {
+	csv, err := Load("assets/infinite/infinite_csv.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmx, err := Load("assets/infinite/infinite_base64_zlib.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks := tmx.Layers[0].ChunkTiles()
+	if len(chunks) != 2 {
+		t.Fatalf("expected 2 chunks, got %d", len(chunks))
+	}
+	for origin, tiles := range csv.Layers[0].ChunkTiles() {
+		if !slices.Equal(tileGIDs(chunks[origin]), tileGIDs(tiles)) {
+			t.Errorf("chunk %v: expected %v, got %v", origin, tileGIDs(tiles), tileGIDs(chunks[origin]))
+		}
+	}
+}
+
+func TestImageLayer(t *testing.T) {
+	tmx, err := Load("assets/embedded/imagelayer.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tmx.ImageLayers) != 1 {
+		t.Fatalf("expected 1 image layer, got %d", len(tmx.ImageLayers))
+	}
+	if tmx.ImageLayers[0].OffsetX != 8 || tmx.ImageLayers[0].OffsetY != 4 {
+		t.Errorf("unexpected image layer offset: %d,%d", tmx.ImageLayers[0].OffsetX, tmx.ImageLayers[0].OffsetY)
+	}
+	if tmx.ImageLayers[0].Image.Image == nil {
+		t.Errorf("image layer Image.Image should not be null")
+	}
+}
+
+func TestImageLayerRepeat(t *testing.T) {
+	tmx, err := Decode(strings.NewReader(`<map width="1" height="1">
+ <imagelayer id="1" name="Sky" repeatx="1"/>
+</map>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if il := tmx.ImageLayers[0]; !il.RepeatX || il.RepeatY || len(il.UnknownAttrs) != 0 {
+		t.Errorf("unexpected image layer: %+v", il)
+	}
+	var buf strings.Builder
+	if err := tmx.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `<imagelayer id="1" name="Sky" repeatx="1">`) {
+		t.Errorf("encoded map should repeat the image layer along x:\n%s", buf.String())
+	}
+
+	tmj, err := DecodeJSON(strings.NewReader(`{"layers":[{"type":"imagelayer","repeaty":true}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if il := tmj.ImageLayers[0]; il.RepeatX || !il.RepeatY {
+		t.Errorf("unexpected image layer: %+v", il)
+	}
+}
+
+func TestGroup(t *testing.T) {
+	tmx, err := Load("assets/embedded/group.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tmx.Groups) != 1 {
+		t.Fatalf("expected 1 group, got %d", len(tmx.Groups))
+	}
+	group := tmx.Groups[0]
+	if len(group.Layers) != 1 || len(group.Layers[0].Tiles) != 4 {
+		t.Fatalf("expected 1 layer of 4 tiles in group")
+	}
+	if group.Layers[0].Tiles[3].ID != 3 {
+		t.Errorf("expected tile ID 3, got %d", group.Layers[0].Tiles[3].ID)
+	}
+	if !group.Layers[0].Locked || group.Locked {
+		t.Errorf("expected only the group layer to be locked")
+	}
+	if len(group.Groups) != 1 || len(group.Groups[0].ImageLayers) != 1 {
+		t.Fatalf("expected 1 nested group with 1 image layer")
+	}
+	if group.Groups[0].ImageLayers[0].Image.Image == nil {
+		t.Errorf("nested image layer Image.Image should not be null")
+	}
+}
+
+func TestObjectKind(t *testing.T) {
+	tmx, err := Load("assets/embedded/objects.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ObjectKind{RectangleObject, EllipseObject, PointObject, PolygonObject, PolyLineObject, TileObject, TextObject}
+	objects := tmx.ObjectGroups[0].Objects
+	if len(objects) != len(expected) {
+		t.Fatalf("expected %d objects, got %d", len(expected), len(objects))
+	}
+	for i, object := range objects {
+		if kind := object.Kind(); kind != expected[i] {
+			t.Errorf("object %q: expected kind %d, got %d", object.Name, expected[i], kind)
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	tmx, err := Load("assets/embedded/objects.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := tmx.ObjectGroups[0].Objects[6].Text
+	if text == nil {
+		t.Fatalf("text should not be null")
+	}
+	if text.Value != "Hello World" {
+		t.Errorf("unexpected text value: %q", text.Value)
+	}
+	if text.FontFamily != "sans-serif" || text.PixelSize != 16 {
+		t.Errorf("expected default font, got %q %d", text.FontFamily, text.PixelSize)
+	}
+	if !text.Wrap || text.Color != "#ff0000" || text.HAlign != "center" || text.VAlign != "top" {
+		t.Errorf("unexpected text style: %+v", text)
+	}
+}
+
+func TestLoadFS(t *testing.T) {
+	tmx, err := LoadFS(os.DirFS("assets"), "external/track1_bg.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmx.TileSets[0].Image.Image == nil {
+		t.Errorf("tileset Image.Image should not be null")
+	}
+}
+
+func TestImageLoader(t *testing.T) {
+	var sources []string
+	options := LoadOptions{
+		ImageLoader: func(source string) (image.Image, error) {
+			sources = append(sources, source)
+			return image.NewRGBA(image.Rect(0, 0, 176, 144)), nil
+		},
+	}
+
+	embedded, err := os.Open("assets/embedded/overworld.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer embedded.Close()
+
+	tmx, err := options.Decode(embedded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmx.TileSets[0].Image.Image == nil {
+		t.Errorf("tileset Image.Image should not be null")
+	}
+	if len(sources) != 1 || sources[0] != "overworld.png" {
+		t.Errorf("unexpected image sources: %v", sources)
+	}
+}
+
+func TestSkipImages(t *testing.T) {
+	tmx, err := LoadOptions{SkipImages: true}.Load("assets/external/track1_bg.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmx.TileSets[0].Image == nil {
+		t.Fatalf("tileset Image should not be null")
+	}
+	if tmx.TileSets[0].Image.Image != nil {
+		t.Errorf("tileset Image.Image should be null")
+	}
+	if tmx.TileSets[0].Image.Width != 128 || tmx.TileSets[0].Image.Height != 16 {
+		t.Errorf("unexpected tileset image size: %dx%d", tmx.TileSets[0].Image.Width, tmx.TileSets[0].Image.Height)
+	}
+}
+
+func layerMap(width, height int, data string) string {
+	return fmt.Sprintf(`<map width="%d" height="%d"><tileset firstgid="1"/><layer name="Layer 1" width="%d" height="%d">%s</layer></map>`,
+		width, height, width, height, data)
+}
+
+func TestInvalidDataSize(t *testing.T) {
+	tests := map[string]string{
+		"xml":          `<data><tile gid="1"/><tile gid="1"/><tile gid="1"/></data>`,
+		"csv":          `<data encoding="csv">1,1,1</data>`,
+		"csv too long": `<data encoding="csv">1,1,1,1,1</data>`,
+		"base64":       `<data encoding="base64">AQAAAAEAAAABAAAA</data>`,
+		"base64 bytes": `<data encoding="base64">AQAAAAEAAAABAAAAAQAA</data>`,
+	}
+	for name, data := range tests {
+		_, err := Decode(strings.NewReader(layerMap(2, 2, data)))
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+			continue
+		}
+		if !strings.Contains(err.Error(), "invalid layer data size") {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		}
+	}
+}
+
+func TestCSVTrailingComma(t *testing.T) {
+	tmx, err := Decode(strings.NewReader(layerMap(2, 2, "<data encoding=\"csv\">\n1,2,\n3,4,\n</data>")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tmx.Layers[0].Tiles) != 4 || tmx.Layers[0].Tiles[3].ID != 3 {
+		t.Errorf("unexpected tiles: %v", tmx.Layers[0].Tiles)
+	}
+}
+
+func TestInvalidGIDError(t *testing.T) {
+	_, err := Decode(strings.NewReader(`<map width="2" height="1"><tileset firstgid="5"/><layer name="Ground" width="2" height="1"><data encoding="csv">5,2</data></layer></map>`))
+	var invalid *InvalidGIDError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("expected an InvalidGIDError, got %v", err)
+	}
+	if *invalid != (InvalidGIDError{GID: 2, Layer: "Ground", Index: 1}) {
+		t.Errorf("unexpected error: %+v", invalid)
+	}
+	if strings.HasSuffix(err.Error(), "\n") {
+		t.Errorf("error should not end with a newline: %q", err.Error())
+	}
+}
+
+func TestIgnoreInvalidGID(t *testing.T) {
+	tmx, err := LoadOptions{IgnoreInvalidGID: true}.Decode(strings.NewReader(`<map width="2" height="1"><tileset firstgid="5"/><layer name="Ground" width="2" height="1"><data encoding="csv">5,2</data></layer></map>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tiles := tmx.Layers[0].Tiles; tiles[0].Nil || !tiles[1].Nil {
+		t.Errorf("expected the invalid GID to be replaced by NilTile: %v", tiles)
+	}
+	if len(tmx.InvalidGIDs) != 1 || *tmx.InvalidGIDs[0] != (InvalidGIDError{GID: 2, Layer: "Ground", Index: 1}) {
+		t.Errorf("unexpected invalid GIDs: %v", tmx.InvalidGIDs)
+	}
+
+	var buf strings.Builder
+	if err := tmx.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if s := `<data encoding="csv">` + "\n5,2\n</data>"; !strings.Contains(buf.String(), s) {
+		t.Errorf("encoded map should keep the invalid GID %s:\n%s", s, buf.String())
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	tmx, err := Load("assets/embedded/group.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	group := tmx.Groups[0]
+	if group.Opacity != 0.5 || !group.Visible {
+		t.Errorf("unexpected group opacity and visibility: %f %t", group.Opacity, group.Visible)
+	}
+	if group.Layers[0].Opacity != 1 || !group.Layers[0].Visible {
+		t.Errorf("unexpected layer opacity and visibility: %f %t", group.Layers[0].Opacity, group.Layers[0].Visible)
+	}
+	if group.Layers[0].ParallaxX != 1 || group.Layers[0].ParallaxY != 1 {
+		t.Errorf("unexpected layer parallax: %f %f", group.Layers[0].ParallaxX, group.Layers[0].ParallaxY)
+	}
+	imageLayer := group.Groups[0].ImageLayers[0]
+	if imageLayer.Opacity != 1 || !imageLayer.Visible {
+		t.Errorf("unexpected image layer opacity and visibility: %f %t", imageLayer.Opacity, imageLayer.Visible)
+	}
+
+	tmx, err = Decode(strings.NewReader(`<map><objectgroup visible="0"><object/></objectgroup></map>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmx.ObjectGroups[0].Visible || tmx.ObjectGroups[0].Opacity != 1 {
+		t.Errorf("unexpected object group opacity and visibility: %f %t", tmx.ObjectGroups[0].Opacity, tmx.ObjectGroups[0].Visible)
+	}
+	if !tmx.ObjectGroups[0].Objects[0].Visible {
+		t.Errorf("object should be visible")
+	}
+}
+
+func TestNestedTileSet(t *testing.T) {
+	tmx, err := Load("assets/nested/track1_bg.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmx.TileSets[0].Image.Image == nil {
+		t.Errorf("tileset Image.Image should not be null")
+	}
+}
+
+func TestDecodeDir(t *testing.T) {
+	external, err := os.Open("assets/external/track1_bg.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer external.Close()
+
+	tmx, err := DecodeDir(external, "assets/external")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmx.TileSets[0].Image == nil || tmx.TileSets[0].Image.Image == nil {
+		t.Errorf("tileset Image.Image should not be null")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	data, err := os.ReadFile("assets/external/track1_bg.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tmx Map
+	if err := xml.Unmarshal(data, &tmx); err != nil {
+		t.Fatal(err)
+	}
+	if err := tmx.Resolve("assets/external"); err != nil {
+		t.Fatal(err)
+	}
+	if tmx.TileSets[0].Image == nil || tmx.TileSets[0].Image.Image == nil {
+		t.Errorf("the external tileset and its image should be loaded")
+	}
+	if len(tmx.Layers[0].Tiles) != tmx.Width*tmx.Height || tmx.Layers[0].Tiles[0].TileSet != tmx.TileSets[0] {
+		t.Errorf("layer tiles should be resolved")
+	}
+}
+
+func TestLoadBytes(t *testing.T) {
+	data, err := os.ReadFile("assets/external/track1_bg.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmx, err := LoadBytes(data, "assets/external")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmx.TileSets[0].Image == nil || tmx.TileSets[0].Image.Image == nil {
+		t.Errorf("the external tileset and its image should be loaded")
+	}
+
+	unresolved, err := LoadBytes(data, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unresolved.TileSets[0].Image != nil {
+		t.Errorf("the external tileset should not be loaded without a base directory")
+	}
+}
+
+func TestSkipExternalTileSets(t *testing.T) {
+	fsys := fstest.MapFS{"map.tmx": &fstest.MapFile{Data: []byte(`<map width="2" height="1">
+ <tileset firstgid="1" source="missing.tsx"/>
+ <layer name="Ground" width="2" height="1"><data encoding="csv">1,3</data></layer>
+</map>`)}}
+	if _, err := LoadFS(fsys, "map.tmx"); err == nil {
+		t.Errorf("expected an error for a missing tileset")
+	}
+
+	tmx, err := LoadOptions{SkipExternalTileSets: true}.LoadFS(fsys, "map.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tileSet := tmx.TileSets[0]; tileSet.Source != "missing.tsx" || tileSet.Name != "" {
+		t.Errorf("the tileset should be left as a reference: %+v", tileSet)
+	}
+	if tile := tmx.Layers[0].Tiles[1]; tile.TileSet != tmx.TileSets[0] || tile.ID != 2 {
+		t.Errorf("unexpected tile: %+v", tile)
+	}
+}
+
+func TestImageData(t *testing.T) {
+	tmx, err := Load("assets/embedded/imagedata.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := tmx.TileSets[0].Image.Image
+	if img == nil {
+		t.Fatalf("tileset Image.Image should not be null")
+	}
+	if img.Bounds() != image.Rect(0, 0, 128, 16) {
+		t.Errorf("unexpected image bounds: %v", img.Bounds())
+	}
+}
+
+func TestImageDecoder(t *testing.T) {
+	fsys := fstest.MapFS{
+		"tiles.webp": &fstest.MapFile{Data: []byte("RIFF....WEBP")},
+		"map.tmx": &fstest.MapFile{Data: []byte(`<map width="1" height="1" tilewidth="16" tileheight="16">
+ <tileset firstgid="1" name="tiles" tilewidth="16" tileheight="16" tilecount="4" columns="2"><image source="tiles.webp" width="32" height="32"/></tileset>
+</map>`)},
+	}
+	if _, err := LoadFS(fsys, "map.tmx"); err == nil {
+		t.Errorf("expected an error for an unknown image format")
+	}
+
+	var decoded []string
+	options := LoadOptions{ImageDecoder: func(r io.Reader) (image.Image, error) {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		decoded = append(decoded, string(data))
+		return image.NewRGBA(image.Rect(0, 0, 32, 32)), nil
+	}}
+	tmx, err := options.LoadFS(fsys, "map.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmx.TileSets[0].Image.Image == nil || len(decoded) != 1 || decoded[0] != "RIFF....WEBP" {
+		t.Errorf("the image should be decoded by the image decoder, got %q", decoded)
+	}
+
+	if _, err := options.Load("assets/embedded/imagedata.tmx"); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 2 {
+		t.Errorf("embedded images should be decoded by the image decoder")
+	}
+}
+
+func BenchmarkLoadTileSets(b *testing.B) {
+	png, err := os.ReadFile("assets/embedded/overworld.png")
+	if err != nil {
+		b.Fatal(err)
+	}
+	fsys := fstest.MapFS{}
+	var tileSets strings.Builder
+	for i := 0; i < 12; i++ {
+		fmt.Fprintf(&tileSets, `<tileset firstgid="%d" name="overworld" tilewidth="16" tileheight="16" tilecount="99" columns="11"><image source="overworld%d.png" width="176" height="144"/></tileset>`, i*99+1, i)
+		fsys[fmt.Sprintf("overworld%d.png", i)] = &fstest.MapFile{Data: png}
+	}
+	fsys["map.tmx"] = &fstest.MapFile{Data: []byte(`<map width="1" height="1" tilewidth="16" tileheight="16">` + tileSets.String() + `</map>`)}
+
+	// The sequential baseline runs the tileset jobs on a single worker, as runParallel uses GOMAXPROCS workers.
+	for _, procs := range []struct {
+		name string
+		n    int
+	}{{"sequential", 1}, {"parallel", runtime.GOMAXPROCS(0)}} {
+		b.Run(procs.name, func(b *testing.B) {
+			defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(procs.n))
+			for i := 0; i < b.N; i++ {
+				if _, err := LoadFS(fsys, "map.tmx"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestCollectionTileSet(t *testing.T) {
+	tmx, err := Load("assets/collection/objects.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tiles := tmx.TileSets[0].Tiles
+	if len(tiles) != 2 {
+		t.Fatalf("expected 2 tiles, got %d", len(tiles))
+	}
+	for _, tile := range tiles {
+		if tile.Image.Image == nil {
+			t.Errorf("tile %d Image.Image should not be null", tile.ID)
+		}
+	}
+	if tiles[1].Image.Image.Bounds() != image.Rect(0, 0, 128, 16) {
+		t.Errorf("unexpected tile image bounds: %v", tiles[1].Image.Image.Bounds())
+	}
+	if tiles[0].Type != "" || tiles[0].Probability != 1 {
+		t.Errorf("expected an untyped tile with the default probability, got %q and %f", tiles[0].Type, tiles[0].Probability)
+	}
+	if tiles[1].Type != "wall" || tiles[1].Probability != 0.5 {
+		t.Errorf("expected a wall tile with probability 0.5, got %q and %f", tiles[1].Type, tiles[1].Probability)
+	}
+	if solid, ok := tiles[1].Property("solid"); !ok || solid != "true" {
+		t.Errorf("expected solid=true, got %q (%t)", solid, ok)
+	}
+	if grid := tmx.TileSets[0].Grid; grid == nil || *grid != (Grid{Orientation: "orthogonal", Width: 1, Height: 1}) {
+		t.Errorf("unexpected grid: %+v", grid)
+	}
+	if offset := tmx.TileSets[0].TileOffset; offset != (TileOffset{X: -8, Y: 4}) {
+		t.Errorf("unexpected tile offset: %+v", offset)
+	}
+}
+
+func TestGrid(t *testing.T) {
+	tmx, err := Decode(strings.NewReader(`<map version="1.2" orientation="isometric" width="1" height="1" tilewidth="64" tileheight="32">
+ <tileset firstgid="1" name="objects" tilewidth="64" tileheight="64" tilecount="0" columns="0">
+  <grid orientation="isometric" width="64" height="32"/>
+ </tileset>
+</map>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if grid := tmx.TileSets[0].Grid; grid == nil || *grid != (Grid{Orientation: "isometric", Width: 64, Height: 32}) {
+		t.Errorf("unexpected grid: %+v", grid)
+	}
+}
+
+func TestLoadContext(t *testing.T) {
+	if _, err := LoadContext(context.Background(), "assets/external/track1_bg.tmx"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	loaded := 0
+	options := LoadOptions{ImageLoader: func(source string) (image.Image, error) {
+		loaded++
+		cancel()
+		return image.NewRGBA(image.Rect(0, 0, 1, 1)), nil
+	}}
+	_, err := options.LoadContext(ctx, "assets/collection/objects.tmx")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a canceled error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "overworld.png") && !strings.Contains(err.Error(), "track1_bg.png") {
+		t.Errorf("error should name the asset in progress: %v", err)
+	}
+	if loaded != 1 {
+		t.Errorf("expected the load to stop after the first image, %d images loaded", loaded)
+	}
+}
+
+func TestSniffCompression(t *testing.T) {
+	expected, err := Load("assets/external/track1_bg.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := "eJzF0EkKgDAMBdDUeZ7HnUfxaB7Vo/jFFoJULSgYeItfkoZWEJEFNogfOODSUR74EEghRCzzs1DOxgYzOqpPVwmkkLGcQ8HOTGdKDX5PBTU00D7oTv1f5B6GizfthT+eYFH53P82jze75f4Z1qv+t3kDe18INA=="
+	tmx, err := Decode(strings.NewReader(layerMap(32, 6, `<data encoding="base64">`+data+`</data>`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	layer := tmx.Layers[0]
+	if layer.Data.Compression != "zlib" {
+		t.Errorf("expected zlib compression to be detected, got %q", layer.Data.Compression)
+	}
+	for i, tile := range expected.Layers[0].Tiles {
+		if layer.Tiles[i].ID != tile.ID || layer.Tiles[i].Nil != tile.Nil {
+			t.Fatalf("tile %d: expected %+v, got %+v", i, tile, layer.Tiles[i])
+		}
+	}
+	if len(tmx.Warnings) != 1 || !strings.Contains(tmx.Warnings[0], "Layer 1") {
+		t.Errorf("expected a warning for the layer, got %v", tmx.Warnings)
+	}
+	if sniffCompression([]byte{0x1f, 0x8b, 8, 0}) != "gzip" || sniffCompression([]byte{1, 0, 0, 0}) != "" {
+		t.Errorf("unexpected compression sniffing")
+	}
+}
+
+func TestFlippedTiles(t *testing.T) {
+	gids := []GID{1 | horizontalFlip, 2 | verticalFlip, 3 | diagonalFlip, 4 | horizontalFlip | verticalFlip | diagonalFlip}
+	var xmlData, csvData []string
+	raw := make([]byte, 0, 4*len(gids))
+	for _, gid := range gids {
+		xmlData = append(xmlData, fmt.Sprintf(`<tile gid="%d"/>`, gid))
+		csvData = append(csvData, fmt.Sprint(gid))
+		raw = binary.LittleEndian.AppendUint32(raw, uint32(gid))
+	}
+	encodings := map[string]string{
+		"xml":    `<data>` + strings.Join(xmlData, "") + `</data>`,
+		"csv":    `<data encoding="csv">` + strings.Join(csvData, ",") + `</data>`,
+		"base64": `<data encoding="base64">` + base64.StdEncoding.EncodeToString(raw) + `</data>`,
+	}
+	for name, data := range encodings {
+		tmx, err := Decode(strings.NewReader(layerMap(4, 1, data)))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !slices.Equal(tmx.Layers[0].GIDs, gids) {
+			t.Errorf("%s: expected GIDs %v, got %v", name, gids, tmx.Layers[0].GIDs)
+		}
+		for i, tile := range tmx.Layers[0].Tiles {
+			horizontal, vertical, diagonal := gids[i].Flags()
+			if tile.ID != GID(i) || tile.HorizontalFlip != horizontal || tile.VerticalFlip != vertical ||
+				tile.DiagonalFlip != diagonal {
+				t.Errorf("%s: tile %d: unexpected %+v", name, i, tile)
+			}
+			if tile.gid() != gids[i] {
+				t.Errorf("%s: tile %d: expected GID %d, got %d", name, i, gids[i], tile.gid())
+			}
+		}
+	}
+}
+
+func TestCSVFlippedGIDs(t *testing.T) {
+	tmx, err := Decode(strings.NewReader(layerMap(2, 1, `<data encoding="csv">2147483649,3758096386</data>`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tiles := tmx.Layers[0].Tiles
+	if tiles[0].ID != 0 || !tiles[0].HorizontalFlip || tiles[0].VerticalFlip {
+		t.Errorf("unexpected tile: %+v", tiles[0])
+	}
+	if tiles[1].ID != 1 || !tiles[1].HorizontalFlip || !tiles[1].VerticalFlip || !tiles[1].DiagonalFlip {
+		t.Errorf("unexpected tile: %+v", tiles[1])
+	}
+
+	if _, err := Decode(strings.NewReader(layerMap(1, 1, `<data encoding="csv">4294967296</data>`))); err == nil {
+		t.Errorf("expected an error for a GID overflowing 32 bits")
+	}
+}
+
+func TestTileSetCache(t *testing.T) {
+	var sources []string
+	options := LoadOptions{
+		TileSetCache: &TileSetCache{},
+		ImageLoader: func(source string) (image.Image, error) {
+			sources = append(sources, source)
+			return image.NewRGBA(image.Rect(0, 0, 176, 144)), nil
+		},
+	}
+
+	first, err := options.Load("assets/external/track1_bg.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := options.Load("assets/external/track1_bg.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 1 {
+		t.Errorf("the tileset image should be loaded once, got %v", sources)
+	}
+	if first.TileSets[0].Image.Image != second.TileSets[0].Image.Image {
+		t.Errorf("the tileset image should be shared between the maps")
+	}
+	if second.TileSets[0].FirstGID != 1 || second.TileSets[0].Source == "" {
+		t.Errorf("unexpected tileset: %+v", second.TileSets[0])
+	}
+}
+
+func TestLayerGIDs(t *testing.T) {
+	tmx, err := LoadOptions{IgnoreInvalidGID: true}.Decode(strings.NewReader(`<map width="2" height="1"><tileset firstgid="5"/><layer name="Ground" width="2" height="1"><data encoding="csv">5,2</data></layer></map>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(tmx.Layers[0].GIDs, []GID{5, 2}) || !tmx.Layers[0].Tiles[1].Nil {
+		t.Errorf("invalid GIDs should be kept: %v", tmx.Layers[0].GIDs)
+	}
+
+	infinite, err := Load("assets/infinite/infinite_csv.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, chunk := range infinite.Layers[0].Data.Chunk {
+		if !slices.Equal(chunk.GIDs, tileGIDs(chunk.Tiles)) {
+			t.Errorf("chunk %d,%d: GIDs %v do not match the tiles", chunk.X, chunk.Y, chunk.GIDs)
+		}
+	}
+}
+
+func TestBase64Whitespace(t *testing.T) {
+	gids := []GID{1, 2, 3, 4, 5}
+	raw := make([]byte, 0, 4*len(gids))
+	for _, gid := range gids {
+		raw = binary.LittleEndian.AppendUint32(raw, uint32(gid))
+	}
+	encoded := base64.StdEncoding.EncodeToString(raw)
+	if !strings.HasSuffix(encoded, "=") {
+		t.Fatalf("test data should be padded: %s", encoded)
+	}
+
+	tests := map[string]string{
+		"newlines":        encoded[:10] + "\n" + encoded[10:20] + "\r\n" + encoded[20:],
+		"spaces and tabs": "\n\t " + encoded[:7] + " \t " + encoded[7:] + "\n ",
+		"no padding":      strings.TrimRight(encoded, "="),
+	}
+	for name, data := range tests {
+		tmx, err := Decode(strings.NewReader(layerMap(5, 1, `<data encoding="base64">`+data+`</data>`)))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !slices.Equal(tmx.Layers[0].GIDs, gids) {
+			t.Errorf("%s: expected %v, got %v", name, gids, tmx.Layers[0].GIDs)
+		}
+	}
+}
+
+func TestCharsetReader(t *testing.T) {
+	fsys := fstest.MapFS{
+		"map.tmx": &fstest.MapFile{Data: []byte("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n" +
+			"<map width=\"1\" height=\"1\">\n" +
+			" <tileset firstgid=\"1\" source=\"tiles.tsx\"/>\n" +
+			" <layer name=\"Caf\xe9\" width=\"1\" height=\"1\"><data encoding=\"csv\">1</data></layer>\n" +
+			"</map>")},
+		"tiles.tsx": &fstest.MapFile{Data: []byte("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n" +
+			"<tileset name=\"Pi\xf1a\" tilewidth=\"8\" tileheight=\"8\" tilecount=\"1\" columns=\"1\"/>")},
+	}
+	if _, err := (LoadOptions{SkipImages: true}).LoadFS(fsys, "map.tmx"); err == nil {
+		t.Fatalf("expected an error without a charset reader")
+	}
+
+	latin1 := func(charset string, input io.Reader) (io.Reader, error) {
+		if !strings.EqualFold(charset, "ISO-8859-1") {
+			return nil, fmt.Errorf("unsupported charset: %s", charset)
+		}
+		data, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return strings.NewReader(string(runes)), nil
+	}
+	tmx, err := (LoadOptions{SkipImages: true, CharsetReader: latin1}).LoadFS(fsys, "map.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmx.Layers[0].Name != "Café" || tmx.TileSets[0].Name != "Piña" {
+		t.Errorf("unexpected names: %q, %q", tmx.Layers[0].Name, tmx.TileSets[0].Name)
+	}
+}
+
+func TestEmptyLayers(t *testing.T) {
+	tmx, err := Decode(strings.NewReader(`<map width="3" height="2" tilewidth="8" tileheight="8">
+ <tileset firstgid="1" name="tiles" tilewidth="8" tileheight="8" tilecount="4" columns="2"/>
+ <layer id="1" name="XML" width="3" height="2"><data></data></layer>
+ <layer id="2" name="CSV" width="3" height="2"><data encoding="csv">
+</data></layer>
+ <layer id="3" name="Base64" width="3" height="2"><data encoding="base64" compression="zlib"> </data></layer>
+ <layer id="4" name="Missing" width="3" height="2"/>
+</map>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, layer := range tmx.Layers {
+		if len(layer.Tiles) != 6 || len(layer.GIDs) != 6 {
+			t.Errorf("layer %s: expected 6 tiles, got %d", layer.Name, len(layer.Tiles))
+		}
+		for i, tile := range layer.Tiles {
+			if !tile.Nil {
+				t.Errorf("layer %s: tile %d should be nil", layer.Name, i)
+			}
+		}
+	}
+}
+
+func TestEncodingMismatch(t *testing.T) {
+	for _, test := range []struct{ data, expected string }{
+		{`<data encoding="csv"><tile gid="1"/><tile gid="2"/></data>`, `layer "Ground": csv encoded data holds <tile> elements`},
+		{`<data>1,2</data>`, `layer "Ground": data without encoding holds text`},
+	} {
+		_, err := Decode(strings.NewReader(`<map width="2" height="1">
+ <tileset firstgid="1" name="tiles" tilewidth="8" tileheight="8" tilecount="4" columns="2"/>
+ <layer name="Ground" width="2" height="1">` + test.data + `</layer>
+</map>`))
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("%s: expected error %q, got %v", test.data, test.expected, err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package tmxmap
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+)
+
+// Validate checks the consistency of the map: tilesets are sorted by first GID, layer data matches the layer
+// dimensions and object GIDs resolve to a tileset. For maps loaded from a file system, it also checks that the
+// referenced tilesets and images exist. All the problems found are joined in the returned error.
+func (m *Map) Validate() error {
+	var errs []error
+	for i, ts := range m.TileSets {
+		if ts.FirstGID == 0 {
+			errs = append(errs, fmt.Errorf("tileset %q: first GID must be at least 1", ts.Name))
+		}
+		if i > 0 && ts.FirstGID <= m.TileSets[i-1].FirstGID {
+			errs = append(errs, fmt.Errorf("tileset %q: first GID %d is not greater than %d", ts.Name, ts.FirstGID,
+				m.TileSets[i-1].FirstGID))
+		}
+		if ts.Source != "" {
+			errs = append(errs, m.validateFile(path.Join(m.baseDir, ts.Source)))
+		}
+		if ts.Image != nil && ts.Image.Source != "" {
+			errs = append(errs, m.validateFile(path.Join(ts.dir, ts.Image.Source)))
+		}
+		for _, tile := range ts.Tiles {
+			if tile.Image.Source != "" {
+				errs = append(errs, m.validateFile(path.Join(ts.dir, tile.Image.Source)))
+			}
+		}
+	}
+
+	m.walkLayers(m.Layers, m.Groups, func(l *Layer) {
+		errs = append(errs, l.validate())
+	})
+	m.walkObjectGroups(m.ObjectGroups, m.Groups, func(og *ObjectGroup) error {
+		for _, object := range og.Objects {
+			if object.GID != 0 && m.tileSetIndex(GID(object.GID).Clear()) < 0 {
+				errs = append(errs, fmt.Errorf("object %d: invalid tile GID: %d", object.ID, object.GID))
+			}
+		}
+		return nil
+	})
+	m.walkImageLayers(m.ImageLayers, m.Groups, func(il *ImageLayer) {
+		if il.Image.Source != "" {
+			errs = append(errs, m.validateFile(path.Join(m.baseDir, il.Image.Source)))
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// validate checks the decoded tiles of the layer, or its data when the tiles have not been decoded.
+func (l *Layer) validate() error {
+	if len(l.Data.Chunk) > 0 {
+		for i := range l.Data.Chunk {
+			chunk := &l.Data.Chunk[i]
+			if chunk.Tiles != nil && len(chunk.Tiles) != chunk.Width*chunk.Height {
+				return fmt.Errorf("layer %q: %w", l.Name, invalidSizeError(chunk.Width*chunk.Height, len(chunk.Tiles)))
+			}
+			if chunk.Tiles == nil {
+				if _, err := chunk.decode(&l.Data); err != nil {
+					return fmt.Errorf("layer %q: %w", l.Name, err)
+				}
+			}
+		}
+		return nil
+	}
+
+	if l.Tiles != nil {
+		if len(l.Tiles) != l.Width*l.Height {
+			return fmt.Errorf("layer %q: %w", l.Name, invalidSizeError(l.Width*l.Height, len(l.Tiles)))
+		}
+		return nil
+	}
+	if _, err := l.decode(); err != nil {
+		return fmt.Errorf("layer %q: %w", l.Name, err)
+	}
+	return nil
+}
+
+// validateFile checks that a file referenced by the map exists. Maps not loaded from a file system are not checked.
+func (m *Map) validateFile(name string) error {
+	if m.fsys == nil {
+		return nil
+	}
+	if _, err := fs.Stat(m.fsys, name); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *Map) walkLayers(layers []Layer, groups []Group, fn func(l *Layer)) {
+	for i := range layers {
+		fn(&layers[i])
+	}
+	for i := range groups {
+		m.walkLayers(groups[i].Layers, groups[i].Groups, fn)
+	}
+}
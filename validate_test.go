@@ -0,0 +1,65 @@
+package tmxmap
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestValidate(t *testing.T) {
+	for _, name := range []string{
+		"assets/external/track1_bg.tmx",
+		"assets/embedded/objects.tmx",
+		"assets/infinite/infinite_csv.tmx",
+		"assets/collection/objects.tmx",
+	} {
+		tmx, err := Load(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tmx.Validate(); err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		}
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	tmx, err := LoadOptions{IgnoreInvalidGID: true}.Decode(strings.NewReader(`<map width="2" height="1">
+ <tileset firstgid="10" name="second"/>
+ <tileset firstgid="5" name="first"/>
+ <layer name="Ground" width="2" height="1"><data encoding="csv">5,5</data></layer>
+ <objectgroup name="Objects"><object id="1" gid="2"/></objectgroup>
+</map>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmx.Layers[0].Tiles = tmx.Layers[0].Tiles[:1]
+
+	err = tmx.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, s := range []string{`tileset "first"`, `layer "Ground"`, "object 1"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("expected an error about %s, got: %v", s, err)
+		}
+	}
+}
+
+func TestValidateMissingFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"map.tmx": {Data: []byte(`<map width="1" height="1">
+ <tileset firstgid="1" name="tiles"><image source="missing.png"/></tileset>
+ <layer name="Ground" width="1" height="1"><data encoding="csv">1</data></layer>
+</map>`)},
+	}
+	tmx, err := LoadOptions{SkipImages: true}.LoadFS(fsys, "map.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tmx.Validate(); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected a missing file error, got %v", err)
+	}
+}
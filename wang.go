@@ -0,0 +1,75 @@
+package tmxmap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// WangSet holds the terrain information of a tileset used by automatic tiling.
+type WangSet struct {
+	Name       string      `xml:"name,attr" json:"name"`
+	Class      string      `xml:"class,attr,omitempty" json:"class"`
+	Type       string      `xml:"type,attr" json:"type"`
+	Tile       int         `xml:"tile,attr" json:"tile"`
+	Properties []Property  `xml:"properties>property" json:"properties"`
+	Colors     []WangColor `xml:"wangcolor" json:"colors"`
+	Tiles      []WangTile  `xml:"wangtile" json:"wangtiles"`
+}
+
+// WangColor is a terrain of a wang set. Colors are referenced by wang IDs starting from 1.
+type WangColor struct {
+	Name        string     `xml:"name,attr" json:"name"`
+	Class       string     `xml:"class,attr,omitempty" json:"class"`
+	Color       string     `xml:"color,attr" json:"color"`
+	Tile        int        `xml:"tile,attr" json:"tile"`
+	Probability float64    `xml:"probability,attr" json:"probability"`
+	Properties  []Property `xml:"properties>property" json:"properties"`
+}
+
+// WangTile associates a tile with the wang colors of its edges and corners.
+type WangTile struct {
+	TileID GID    `xml:"tileid,attr" json:"tileid"`
+	WangID string `xml:"wangid,attr" json:"wangid"`
+}
+
+// Wang ID indexes, clockwise from the top edge.
+const (
+	WangTop = iota
+	WangTopRight
+	WangRight
+	WangBottomRight
+	WangBottom
+	WangBottomLeft
+	WangLeft
+	WangTopLeft
+)
+
+// Colors returns the wang color of each edge and corner of the tile, indexed by WangTop to WangTopLeft. Zero means
+// no color. Both the comma separated format and the legacy hexadecimal format are supported.
+func (wt *WangTile) Colors() ([8]int, error) {
+	var colors [8]int
+	if strings.HasPrefix(wt.WangID, "0x") {
+		v, err := strconv.ParseUint(wt.WangID[2:], 16, 32)
+		if err != nil {
+			return colors, fmt.Errorf("invalid wang ID: %s", wt.WangID)
+		}
+		for i := range colors {
+			colors[i] = int(v >> (4 * uint(i)) & 0xf)
+		}
+		return colors, nil
+	}
+
+	tokens := strings.Split(wt.WangID, ",")
+	if len(tokens) != len(colors) {
+		return colors, fmt.Errorf("invalid wang ID: %s", wt.WangID)
+	}
+	for i, token := range tokens {
+		color, err := strconv.Atoi(strings.TrimSpace(token))
+		if err != nil {
+			return colors, fmt.Errorf("invalid wang ID: %s", wt.WangID)
+		}
+		colors[i] = color
+	}
+	return colors, nil
+}
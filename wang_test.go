@@ -0,0 +1,42 @@
+package tmxmap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWangSets(t *testing.T) {
+	tmx, err := Decode(strings.NewReader(`<map>
+ <tileset firstgid="1" name="terrain" tilewidth="16" tileheight="16" tilecount="4" columns="2">
+  <wangsets>
+   <wangset name="Ground" type="corner" tile="-1">
+    <wangcolor name="Grass" color="#00ff00" tile="0" probability="1"/>
+    <wangcolor name="Sand" color="#ffff00" tile="1" probability="0.5"/>
+    <wangtile tileid="2" wangid="0,1,0,2,0,1,0,2"/>
+   </wangset>
+  </wangsets>
+ </tileset>
+</map>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wangSets := tmx.TileSets[0].WangSets
+	if len(wangSets) != 1 || len(wangSets[0].Colors) != 2 || len(wangSets[0].Tiles) != 1 {
+		t.Fatalf("unexpected wang sets: %+v", wangSets)
+	}
+	if wangSets[0].Colors[1].Name != "Sand" || wangSets[0].Colors[1].Probability != 0.5 {
+		t.Errorf("unexpected wang color: %+v", wangSets[0].Colors[1])
+	}
+	colors, err := wangSets[0].Tiles[0].Colors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if colors != [8]int{0, 1, 0, 2, 0, 1, 0, 2} {
+		t.Errorf("unexpected wang colors: %v", colors)
+	}
+
+	legacy := WangTile{WangID: "0x20102010"}
+	if colors, err := legacy.Colors(); err != nil || colors != [8]int{0, 1, 0, 2, 0, 1, 0, 2} {
+		t.Errorf("unexpected legacy wang colors: %v (%v)", colors, err)
+	}
+}